@@ -0,0 +1,485 @@
+//go:build linux
+
+// Command fakeomxplayer stands in for omxplayer so goomx can be exercised
+// without a Raspberry Pi. It accepts omxplayer's command line, writes the
+// D-Bus address and PID files, claims its D-Bus name on an existing bus and
+// answers the MPRIS and omxplayer methods goomx calls with scripted state,
+// then exits when the clip "ends".
+//
+// It is configured with environment variables:
+//
+//	FAKEOMX_DBUS_ADDRESS  bus to register on (default DBUS_SESSION_BUS_ADDRESS)
+//	FAKEOMX_DBUS_FILE     address file to write (default /tmp/omxplayerdbus.$USER);
+//	                      the PID file is the same path with ".pid" appended
+//	FAKEOMX_DURATION      clip length, e.g. "2s" (default 10s)
+//	FAKEOMX_EXIT_CODE     exit status when the clip ends (default 0)
+//	FAKEOMX_START_DELAY   delay before the D-Bus name is claimed
+//	FAKEOMX_STALL_AFTER   position at which playback freezes while still
+//	                      reporting "Playing", as a hung decoder does
+//	FAKEOMX_LOG           file every method call is appended to, one per line
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+)
+
+const (
+	pathMpris      = "/org/mpris/MediaPlayer2"
+	ifaceMpris     = "org.mpris.MediaPlayer2"
+	ifaceOmx       = ifaceMpris + ".omxplayer"
+	ifaceOmxPlayer = ifaceMpris + ".Player"
+	ifaceProps     = "org.freedesktop.DBus.Properties"
+
+	actionExit      = 15
+	actionPlayPause = 16
+	actionPause     = 35
+	actionPlay      = 36
+
+	// exitQuit is omxplayer's exit status after a quit request.
+	exitQuit = 3
+)
+
+// optionsWithValue are the omxplayer options followed by a value.
+var optionsWithValue = map[string]bool{
+	"--dbus_name": true, "--layer": true, "--display": true, "--pos": true, "-l": true,
+	"--vol": true, "-o": true, "--adev": true, "--win": true, "--orientation": true,
+	"--aspect-mode": true, "--timeout": true, "--alpha": true, "--threshold": true,
+	"--subtitles": true, "--font": true, "--font-size": true, "--align": true,
+	"--amp": true, "--audio_queue": true, "--video_queue": true, "--avdict": true,
+	"--key-config": true,
+}
+
+// player is the scripted playback state.
+type player struct {
+	mu       sync.Mutex
+	name     string
+	file     string
+	duration time.Duration
+	stall    time.Duration // position playback freezes at, if not zero
+	offset   time.Duration // position when last resumed or paused
+	resumed  time.Time     // zero while paused
+	volume   float64
+	muted    bool
+	layer    int64
+	subs     bool
+	exitCode int
+	done     chan int // receives the exit status
+	log      *os.File
+}
+
+func (p *player) position() time.Duration {
+	pos := p.offset
+	if !p.resumed.IsZero() {
+		pos += time.Since(p.resumed)
+	}
+	if pos > p.duration {
+		pos = p.duration
+	}
+	if p.stall > 0 && pos > p.stall {
+		pos = p.stall
+	}
+	return pos
+}
+
+func (p *player) setPaused(paused bool) {
+	if paused && !p.resumed.IsZero() {
+		p.offset = p.position()
+		p.resumed = time.Time{}
+	} else if !paused && p.resumed.IsZero() {
+		p.resumed = time.Now()
+	}
+}
+
+func (p *player) seekTo(pos time.Duration) {
+	if pos < 0 {
+		pos = 0
+	}
+	p.offset = pos
+	if !p.resumed.IsZero() {
+		p.resumed = time.Now()
+	}
+}
+
+func (p *player) exit(code int) {
+	select {
+	case p.done <- code:
+	default:
+	}
+}
+
+// exitSoon exits once the reply to the current call has been sent.
+func (p *player) exitSoon(code int) {
+	time.AfterFunc(50*time.Millisecond, func() { p.exit(code) })
+}
+
+// method is a D-Bus method taking the raw message body.
+type method func(p *player, args []interface{}) ([]interface{}, error)
+
+var methods = map[string]method{
+	ifaceMpris + ".Quit": func(p *player, _ []interface{}) ([]interface{}, error) {
+		p.exitSoon(exitQuit)
+		return nil, nil
+	},
+
+	ifaceProps + ".CanQuit":             constant(true),
+	ifaceProps + ".Fullscreen":          constant(false),
+	ifaceProps + ".CanSetFullscreen":    constant(false),
+	ifaceProps + ".CanRaise":            constant(false),
+	ifaceProps + ".HasTrackList":        constant(false),
+	ifaceProps + ".Identity":            constant("OMXPlayer"),
+	ifaceProps + ".SupportedUriSchemes": constant([]string{"file", "http", "rtsp", "rtmp"}),
+	ifaceProps + ".SupportedMimeTypes":  constant([]string{"video/mp4"}),
+	ifaceProps + ".CanGoNext":           constant(false),
+	ifaceProps + ".CanGoPrevious":       constant(false),
+	ifaceProps + ".CanSeek":             constant(true),
+	ifaceProps + ".CanControl":          constant(true),
+	ifaceProps + ".CanPlay":             constant(true),
+	ifaceProps + ".CanPause":            constant(true),
+	ifaceProps + ".Aspect":              constant(16.0 / 9.0),
+	ifaceProps + ".VideoStreamCount":    constant(int64(1)),
+	ifaceProps + ".ResWidth":            constant(int64(1920)),
+	ifaceProps + ".ResHeight":           constant(int64(1080)),
+	ifaceProps + ".MinimumRate":         constant(0.125),
+	ifaceProps + ".MaximumRate":         constant(4.0),
+	ifaceProps + ".Raise":               constant(false),
+	ifaceProps + ".PlaybackStatus": func(p *player, _ []interface{}) ([]interface{}, error) {
+		if p.resumed.IsZero() {
+			return []interface{}{"Paused"}, nil
+		}
+		return []interface{}{"Playing"}, nil
+	},
+	ifaceProps + ".Volume": func(p *player, args []interface{}) ([]interface{}, error) {
+		if len(args) != 0 {
+			v, ok := args[0].(float64)
+			if !ok {
+				return nil, dbus.ErrMsgInvalidArg
+			}
+			p.volume = v
+		}
+		return []interface{}{p.volume}, nil
+	},
+	ifaceProps + ".Mute": func(p *player, _ []interface{}) ([]interface{}, error) {
+		p.muted = true
+		return nil, nil
+	},
+	ifaceProps + ".Unmute": func(p *player, _ []interface{}) ([]interface{}, error) {
+		p.muted = false
+		return nil, nil
+	},
+	ifaceProps + ".Position": func(p *player, _ []interface{}) ([]interface{}, error) {
+		return []interface{}{p.position().Microseconds()}, nil
+	},
+	ifaceProps + ".Duration": func(p *player, _ []interface{}) ([]interface{}, error) {
+		return []interface{}{p.duration.Microseconds()}, nil
+	},
+
+	ifaceOmxPlayer + ".Next":     constant(),
+	ifaceOmxPlayer + ".Previous": constant(),
+	ifaceOmxPlayer + ".Pause": func(p *player, _ []interface{}) ([]interface{}, error) {
+		p.setPaused(!p.resumed.IsZero()) // omxplayer's Pause toggles
+		return nil, nil
+	},
+	ifaceOmxPlayer + ".PlayPause": func(p *player, _ []interface{}) ([]interface{}, error) {
+		p.setPaused(!p.resumed.IsZero())
+		return nil, nil
+	},
+	ifaceOmxPlayer + ".Play": func(p *player, _ []interface{}) ([]interface{}, error) {
+		p.setPaused(false)
+		return nil, nil
+	},
+	ifaceOmxPlayer + ".Stop": func(p *player, _ []interface{}) ([]interface{}, error) {
+		p.exitSoon(exitQuit)
+		return nil, nil
+	},
+	ifaceOmxPlayer + ".Seek": func(p *player, args []interface{}) ([]interface{}, error) {
+		offset, ok := argInt64(args, 0)
+		if !ok {
+			return nil, dbus.ErrMsgInvalidArg
+		}
+		p.seekTo(p.position() + time.Duration(offset)*time.Microsecond)
+		return []interface{}{offset}, nil
+	},
+	ifaceOmxPlayer + ".SetPosition": func(p *player, args []interface{}) ([]interface{}, error) {
+		pos, ok := argInt64(args, 1)
+		if !ok {
+			return nil, dbus.ErrMsgInvalidArg
+		}
+		p.seekTo(time.Duration(pos) * time.Microsecond)
+		return []interface{}{pos}, nil
+	},
+	ifaceOmxPlayer + ".ListSubtitles":  constant([]string{"0:eng:English:subrip:active"}),
+	ifaceOmxPlayer + ".ListAudio":      constant([]string{"0:eng:Stereo:aac:active", "1:fra:Stereo:aac:"}),
+	ifaceOmxPlayer + ".ListVideo":      constant([]string{"0:und::h264:active"}),
+	ifaceOmxPlayer + ".SelectSubtitle": constant(true),
+	ifaceOmxPlayer + ".SelectAudio":    constant(true),
+	ifaceOmxPlayer + ".HideVideo":      constant(),
+	ifaceOmxPlayer + ".UnHideVideo":    constant(),
+	ifaceOmxPlayer + ".ShowSubtitles": func(p *player, _ []interface{}) ([]interface{}, error) {
+		p.subs = true
+		return nil, nil
+	},
+	ifaceOmxPlayer + ".HideSubtitles": func(p *player, _ []interface{}) ([]interface{}, error) {
+		p.subs = false
+		return nil, nil
+	},
+	ifaceOmxPlayer + ".GetSource": func(p *player, _ []interface{}) ([]interface{}, error) {
+		return []interface{}{p.file}, nil
+	},
+	ifaceOmxPlayer + ".OpenUri": func(p *player, args []interface{}) ([]interface{}, error) {
+		uri, ok := argString(args, 0)
+		if !ok {
+			return nil, dbus.ErrMsgInvalidArg
+		}
+		p.file = uri
+		p.seekTo(0)
+		return nil, nil
+	},
+	ifaceOmxPlayer + ".SetLayer": func(p *player, args []interface{}) ([]interface{}, error) {
+		layer, ok := argInt64(args, 0)
+		if !ok {
+			return nil, dbus.ErrMsgInvalidArg
+		}
+		p.layer = layer
+		return nil, nil
+	},
+	ifaceOmxPlayer + ".Action": func(p *player, args []interface{}) ([]interface{}, error) {
+		if len(args) != 1 {
+			return nil, dbus.ErrMsgInvalidArg
+		}
+		action, ok := args[0].(int32)
+		if !ok {
+			return nil, dbus.ErrMsgInvalidArg
+		}
+		switch action {
+		case actionPause:
+			p.setPaused(true)
+		case actionPlay:
+			p.setPaused(false)
+		case actionPlayPause:
+			p.setPaused(!p.resumed.IsZero())
+		case actionExit:
+			p.exitSoon(exitQuit)
+		}
+		return nil, nil
+	},
+}
+
+// constant returns a method replying with values.
+func constant(values ...interface{}) method {
+	return func(*player, []interface{}) ([]interface{}, error) { return values, nil }
+}
+
+func argInt64(args []interface{}, i int) (int64, bool) {
+	if i >= len(args) {
+		return 0, false
+	}
+	v, ok := args[i].(int64)
+	return v, ok
+}
+
+func argString(args []interface{}, i int) (string, bool) {
+	if i >= len(args) {
+		return "", false
+	}
+	v, ok := args[i].(string)
+	return v, ok
+}
+
+// handler routes method calls on pathMpris to methods. It implements
+// dbus.Handler, dbus.ServerObject and dbus.Interface so that methods can take
+// optional arguments, as omxplayer's Volume does.
+type handler struct {
+	p     *player
+	iface string
+}
+
+func (h handler) LookupObject(path dbus.ObjectPath) (dbus.ServerObject, bool) {
+	return h, path == pathMpris
+}
+
+func (h handler) LookupInterface(name string) (dbus.Interface, bool) {
+	return handler{p: h.p, iface: name}, true
+}
+
+func (h handler) LookupMethod(name string) (dbus.Method, bool) {
+	m, ok := methods[h.iface+"."+name]
+	return boundMethod{h.p, h.iface + "." + name, m}, ok
+}
+
+// boundMethod is a method bound to the player, implementing dbus.Method and
+// dbus.ArgumentDecoder.
+type boundMethod struct {
+	p    *player
+	name string
+	fn   method
+}
+
+func (m boundMethod) DecodeArguments(_ *dbus.Conn, _ string, _ *dbus.Message, args []interface{}) ([]interface{}, error) {
+	return args, nil
+}
+
+func (m boundMethod) Call(args ...interface{}) ([]interface{}, error) {
+	m.p.mu.Lock()
+	defer m.p.mu.Unlock()
+	if m.p.log != nil {
+		fmt.Fprintln(m.p.log, strings.TrimSpace(fmt.Sprintln(append([]interface{}{m.p.name, m.name}, args...)...)))
+	}
+	return m.fn(m.p, args)
+}
+
+func (m boundMethod) NumArguments() int                    { return 0 }
+func (m boundMethod) NumReturns() int                      { return 0 }
+func (m boundMethod) ArgumentValue(int) interface{}        { return nil }
+func (m boundMethod) ReturnValue(position int) interface{} { return nil }
+
+// parsePos parses omxplayer's start position, hh:mm:ss or seconds.
+func parsePos(v string) time.Duration {
+	var pos time.Duration
+	for _, field := range strings.Split(v, ":") {
+		n, _ := strconv.Atoi(field)
+		pos = pos*60 + time.Duration(n)*time.Second
+	}
+	return pos
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	if v := os.Getenv(name); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+		fmt.Fprintf(os.Stderr, "fakeomxplayer: invalid %s %q\n", name, v)
+	}
+	return def
+}
+
+func main() {
+	p := &player{name: ifaceOmx, volume: 1, done: make(chan int, 1)}
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch {
+		case arg == "--dbus_name" && i+1 < len(os.Args):
+			p.name = os.Args[i+1]
+			i++
+		case arg == "--layer" && i+1 < len(os.Args):
+			p.layer, _ = strconv.ParseInt(os.Args[i+1], 10, 64)
+			i++
+		case (arg == "--pos" || arg == "-l") && i+1 < len(os.Args):
+			p.offset = parsePos(os.Args[i+1])
+			i++
+		case optionsWithValue[arg]:
+			i++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			p.file = arg
+		}
+	}
+	if p.file == "" {
+		fmt.Fprintln(os.Stderr, "usage: fakeomxplayer [omxplayer options] file")
+		os.Exit(1)
+	}
+	p.duration = envDuration("FAKEOMX_DURATION", 10*time.Second)
+	p.stall = envDuration("FAKEOMX_STALL_AFTER", 0)
+	if v := os.Getenv("FAKEOMX_EXIT_CODE"); v != "" {
+		p.exitCode, _ = strconv.Atoi(v)
+	}
+	if name := os.Getenv("FAKEOMX_LOG"); name != "" {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "fakeomxplayer:", err)
+			os.Exit(1)
+		}
+		p.log = f
+	}
+
+	address := os.Getenv("FAKEOMX_DBUS_ADDRESS")
+	if address == "" {
+		address = os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	}
+	dbusFile := os.Getenv("FAKEOMX_DBUS_FILE")
+	if dbusFile == "" {
+		dbusFile = "/tmp/omxplayerdbus." + os.Getenv("USER")
+	}
+	if err := os.WriteFile(dbusFile, []byte(address+"\n"), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "fakeomxplayer:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(dbusFile+".pid", []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "fakeomxplayer:", err)
+		os.Exit(1)
+	}
+
+	time.Sleep(envDuration("FAKEOMX_START_DELAY", 0))
+	os.Exit(run(p, address))
+}
+
+// run serves p on the bus at address until the clip ends or the player is
+// told to quit, and returns the exit status.
+func run(p *player, address string) int {
+	conn, err := dbus.DialHandler(address, handler{p: p}, dbus.NewDefaultSignalHandler())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fakeomxplayer:", err)
+		return 1
+	}
+	defer conn.Close()
+	if err = conn.Auth(nil); err != nil {
+		fmt.Fprintln(os.Stderr, "fakeomxplayer:", err)
+		return 1
+	}
+	if err = conn.Hello(); err != nil {
+		fmt.Fprintln(os.Stderr, "fakeomxplayer:", err)
+		return 1
+	}
+	p.mu.Lock()
+	p.setPaused(false)
+	p.mu.Unlock()
+	reply, err := conn.RequestName(p.name, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		fmt.Fprintln(os.Stderr, "fakeomxplayer: can not own", p.name, err)
+		return 1
+	}
+
+	go readKeys(p)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case code := <-p.done:
+			return code
+		case <-ticker.C:
+			p.mu.Lock()
+			ended := p.position() >= p.duration
+			p.mu.Unlock()
+			if ended {
+				return p.exitCode
+			}
+		}
+	}
+}
+
+// readKeys handles omxplayer's keyboard commands on stdin: "p" or space
+// toggles pause and "q" quits.
+func readKeys(p *player) {
+	r := bufio.NewReader(os.Stdin)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case 'p', ' ':
+			p.mu.Lock()
+			p.setPaused(!p.resumed.IsZero())
+			p.mu.Unlock()
+		case 'q':
+			p.exit(exitQuit)
+		}
+	}
+}
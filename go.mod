@@ -5,6 +5,7 @@ go 1.25.0
 toolchain go1.25.5
 
 require (
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/godbus/dbus v4.1.0+incompatible
 	github.com/sonnt85/goring v0.0.0-20250303163103-b4533a83266e
 	github.com/sonnt85/gosutils v0.0.0-20251021114853-09b4d7cee7a2
@@ -23,7 +24,6 @@ require (
 	github.com/creack/pty v1.1.24 // indirect
 	github.com/ebitengine/purego v0.10.0 // indirect
 	github.com/fatih/color v1.19.0 // indirect
-	github.com/fsnotify/fsnotify v1.9.0 // indirect
 	github.com/go-ole/go-ole v1.2.6 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/google/uuid v1.6.0 // indirect
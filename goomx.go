@@ -1,14 +1,18 @@
-//go:build linux && arm
+//go:build linux
 
 package goomx
 
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 
 	dbus "github.com/godbus/dbus"
 	"github.com/sonnt85/goring"
@@ -18,14 +22,14 @@ import (
 
 const (
 	envDisplay                     = "DISPLAY"
-	envDbusAddress                 = "DBUS_SESSION_BUS_ADDRESS"
-	envDbusPid                     = "DBUS_SESSION_BUS_PID"
+	envUser                        = "USER"
 	prefixOmxDbusFiles             = "/tmp/omxplayerdbus."
 	suffixOmxDbusPid               = ".pid"
 	pathMpris                      = "/org/mpris/MediaPlayer2"
 	ifaceMpris                     = "org.mpris.MediaPlayer2"
 	ifaceOmx                       = ifaceMpris + ".omxplayer"
 	exeOxmPlayer                   = "omxplayer"
+	exeOmxiv                       = "omxiv"
 	keyPause                       = "p"
 	keyQuit                        = "q"
 	ACTION_DECREASE_SPEED          = 1
@@ -69,10 +73,12 @@ const (
 )
 
 var (
-	user            string
-	home            string
-	fileOmxDbusPath string
-	fileOmxDbusPid  string
+	user string
+	home string
+
+	playersMu  sync.Mutex
+	players    = make(map[string]*Player)
+	playersSeq int
 )
 
 func init() {
@@ -82,144 +88,277 @@ func init() {
 // SetUser sets the username (u) and home directory (h) of the user that new
 // omxplayer processes will be running as. This does not change which user the
 // processes will be spawned as, it is just used to find the correct D-Bus
-// configuration file after a new process has been started.
+// configuration file after a new process has been started. Players that
+// already exist keep their own settings; see Player.SetUser.
 func SetUser(u, h string) {
 	user = u
 	home = h
-	fileOmxDbusPath = prefixOmxDbusFiles + user
-	fileOmxDbusPid = prefixOmxDbusFiles + user + suffixOmxDbusPid
 }
 
-// New returns a new Player instance that can be used to control an OMXPlayer
-// instance that is playing the video located at the specified URL.
-
+// NewPlayer returns the default Player instance, creating it on first use.
+// The default instance drives omxplayer under its standard D-Bus name and is
+// also available as Gplayer; later calls return it unchanged and ignore args.
+// Use NewPlayerInstance to drive more than one omxplayer process.
 func NewPlayer(args ...string) (player *Player, err error) {
+	playersMu.Lock()
+	defer playersMu.Unlock()
 	if Gplayer != nil {
 		return Gplayer, nil
 	}
-	removeDbusFiles()
-	player = &Player{}
+	cfg := DefaultPlayerConfig()
+	cfg.DbusName = ifaceOmx
+	cfg.Args = args
+	if player, err = newPlayer(cfg); err != nil {
+		return nil, err
+	}
 	Gplayer = player
+	return
+}
+
+// NewPlayerInstance returns a new, independent Player that controls its own
+// omxplayer process under the D-Bus name dbusName (passed to omxplayer with
+// --dbus_name). Each instance has its own playlist, service goroutines and
+// D-Bus files, so e.g. one instance per HDMI port can be driven by passing
+// --display in args.
+// If dbusName is empty a unique name is generated. Use NewPlayerWithConfig to
+// change more than the D-Bus name and arguments.
+func NewPlayerInstance(dbusName string, args ...string) (player *Player, err error) {
+	cfg := DefaultPlayerConfig()
+	cfg.DbusName = dbusName
+	cfg.Args = args
+	return NewPlayerWithConfig(cfg)
+}
+
+// nextDbusName returns a generated D-Bus name no player uses yet. playersMu
+// must be held by the caller.
+func nextDbusName() string {
+	for {
+		playersSeq++
+		dbusName := ifaceOmx + strconv.Itoa(playersSeq)
+		if _, ok := players[dbusName]; !ok {
+			return dbusName
+		}
+	}
+}
+
+// newPlayer creates and registers a Player for the validated cfg and starts
+// its service goroutines. playersMu must be held by the caller.
+func newPlayer(cfg PlayerConfig) (player *Player, err error) {
+	if _, ok := players[cfg.DbusName]; ok {
+		return nil, fmt.Errorf("player with dbus name %s already exists", cfg.DbusName)
+	}
+	player = &Player{}
+	player.config = cfg
+	player.dbusName = cfg.DbusName
+	if cfg.DbusAddressFile != "" {
+		player.SetDbusFiles(cfg.DbusAddressFile, cfg.DbusPidFile)
+	}
+	player.backend = detectBackend(cfg.OmxplayerBinary, cfg.Args)
+	if user != "" {
+		player.SetUser(user, home)
+	} else {
+		player.SetUser(sutils.SysGetUsername(), sutils.GetHomeDir())
+	}
+	player.removeDbusFiles()
 	player.condStop = gosyncutils.NewEventOpject[bool]()
 	player.condStopViewPicture = gosyncutils.NewEventOpject[bool]()
 	player.condStartViewPicture = gosyncutils.NewEventOpject[bool]()
 
 	player.condStart = gosyncutils.NewEventOpject[bool]()
+	player.ready = newReadyFlag()
 	player.condFinishCurrentPlaying = gosyncutils.NewEventOpject[struct{}]()
+	player.condPlaylist = gosyncutils.NewEventOpject[struct{}]()
 	player.enablePlay = gosyncutils.NewEventOpject[bool]()
 	player.CommandKeysBuffer = bytes.NewBufferString("")
-	SetUser(sutils.SysGetUsername(), sutils.GetHomeDir())
-	player.currentVolume = 0.03
-	player.argsOmx = args
+	player.currentVolume = cfg.InitialVolume
 	player.SeekStep = gosyncutils.NewEventOpject[int]()
 	player.SeekStep.Set(1)
+	player.mode = cfg.PlaybackMode
 	player.ctx, player.CancelFunc = context.WithCancel(context.Background())
 	player.playingFile = make(chan FilePlay)
 	player.EventLinkedList = goring.NewEventLinkedList[string]()
+	players[cfg.DbusName] = player
+	player.wg.Add(1)
 	go player.__startService()
 	return
 }
 
+// SetUser sets the username (u) and home directory (h) used by this player to
+// locate omxplayer's D-Bus files and to authenticate on the bus. It must be
+// called before the first file starts playing.
+//
+// omxplayer names its D-Bus files after the USER variable. The default
+// instance uses the user's files, /tmp/omxplayerdbus.<user>; every other
+// instance starts omxplayer with USER set to <user>.<D-Bus name>, so it gets
+// files, and a D-Bus daemon, of its own.
+func (p *Player) SetUser(u, h string) {
+	p.user = u
+	p.home = h
+	if p.customDbusFiles {
+		return
+	}
+	p.dbusUser = u
+	if p.dbusName != ifaceOmx {
+		p.dbusUser = u + "." + p.dbusName
+	}
+	p.fileOmxDbusPath = prefixOmxDbusFiles + p.dbusUser
+	p.fileOmxDbusPid = prefixOmxDbusFiles + p.dbusUser + suffixOmxDbusPid
+}
+
+// SetDbusFiles overrides the files this player reads omxplayer's D-Bus address
+// (path) and PID (pid) from, for an omxplayer that writes them elsewhere. If
+// pid is empty it is path with ".pid" appended. omxplayer's USER is then left
+// as it is.
+func (p *Player) SetDbusFiles(path, pid string) {
+	if pid == "" {
+		pid = path + suffixOmxDbusPid
+	}
+	p.customDbusFiles = true
+	p.dbusUser = ""
+	p.fileOmxDbusPath = path
+	p.fileOmxDbusPid = pid
+}
+
+// processEnv returns the environment of the processes the player starts: the
+// program's own with DISPLAY defaulting to :0, then PlayerConfig.Env and the
+// USER that selects the player's D-Bus files. The program's environment is
+// never changed, so players do not affect each other.
+func (p *Player) processEnv() []string {
+	env := os.Environ()
+	if _, ok := os.LookupEnv(envDisplay); !ok {
+		env = append(env, envDisplay+"=:0")
+	}
+	env = append(env, p.config.Env...)
+	if p.dbusUser != "" && p.dbusUser != p.user {
+		env = append(env, envUser+"="+p.dbusUser)
+	}
+	return env
+}
+
+// SetBinary sets the omxplayer executable started for the following playlist
+// entries, "omxplayer" (looked up in PATH) by default, and selects omxplayer
+// as the backend.
+func (p *Player) SetBinary(path string) {
+	p.config.OmxplayerBinary = path
+	p.SetBackend(nil)
+}
+
+// DbusName returns the D-Bus name the player's omxplayer process is
+// registered under.
+func (p *Player) DbusName() string {
+	return p.dbusName
+}
+
 // getDbusConnection establishes and returns a D-Bus connection. The connection
-// is made to the D-Bus service that has been set via the two `DBUS_*`
-// environment variables. Since the connection's `Auth` method attempts to use
-// Go's `os/user` package to get the current user's name and home directory, and
-// `os/user` is not implemented for Linux-ARM, the `authMethods` parameter is
-// specified explicitly rather than passing `nil`.
-func getDbusConnection() (conn *dbus.Conn, err error) {
+// is made to the D-Bus address omxplayer wrote to the player's address file.
+// Since the connection's `Auth` method attempts to use Go's `os/user` package
+// to get the current user's name and home directory, and `os/user` is not
+// implemented for Linux-ARM, the `authMethods` parameter is specified
+// explicitly rather than passing `nil`.
+func (p *Player) getDbusConnection() (conn *dbus.Conn, err error) {
+	// current dbus-daemon releases only accept a numeric uid for EXTERNAL;
+	// the user name is kept for older ones
 	authMethods := []dbus.Auth{
-		dbus.AuthExternal(user),
-		dbus.AuthCookieSha1(user, home),
+		dbus.AuthExternal(strconv.Itoa(os.Getuid())),
+		dbus.AuthExternal(p.user),
+		dbus.AuthCookieSha1(p.user, p.home),
+	}
+
+	path, err := p.getDbusPath()
+	if err != nil {
+		return
 	}
 
 	//	log.Debug("omxplayer: opening dbus session")
-	if conn, err = dbus.SessionBusPrivate(); err != nil {
+	if conn, err = dbus.Dial(path); err != nil {
 		return
 	}
 
 	//	log.Debug("omxplayer: authenticating dbus session")
 	if err = conn.Auth(authMethods); err != nil {
-		return
+		conn.Close()
+		return nil, err
 	}
 
 	//	log.Debug("omxplayer: initializing dbus session")
-	err = conn.Hello()
+	if err = conn.Hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
 	return
 }
 
-// setupDbusEnvironment sets the environment variables that are necessary to
-// establish a D-Bus connection. If the connection's path or PID cannot be read,
-// the associated error is returned.
-func setupDbusEnvironment() (err error) {
-	//	log.Debug("omxplayer: setting up dbus environment")
-
-	path, err := getDbusPath()
-	if err != nil {
-		return
-	}
+// waitDbusFiles waits for omxplayer to write the D-Bus address and PID files
+// of the player. If either cannot be read, the associated error is returned.
+// The player dials the address itself, so nothing is put in the environment.
+func (p *Player) waitDbusFiles() (err error) {
+	//	log.Debug("omxplayer: waiting for dbus files")
 
-	pid, err := getDbusPid()
-	if err != nil {
+	if _, err = p.getDbusPath(); err != nil {
 		return
 	}
-
-	os.Setenv(envDisplay, ":0")
-	os.Setenv(envDbusAddress, path)
-	os.Setenv(envDbusPid, pid)
+	_, err = p.getDbusPid()
 	return
 }
 
 // getDbusPath reads the D-Bus path from the file OMXPlayer writes it's path to.
 // If the file cannot be read, it returns an error, otherwise it returns the
 // path as a string.
-func getDbusPath() (string, error) {
-	if err := sutils.FileWaitForFileExist(fileOmxDbusPath, 500); err != nil {
-		return "", err
-	}
-	return sutils.FileWaitContentsAndRead(fileOmxDbusPath, 500)
+func (p *Player) getDbusPath() (string, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, p.config.DbusFileTimeout)
+	defer cancel()
+	return waitDbusFile(ctx, p.fileOmxDbusPath)
 }
 
-// getDbusPath reads the D-Bus PID from the file OMXPlayer writes it's PID to.
+// getDbusPid reads the D-Bus PID from the file OMXPlayer writes it's PID to.
 // If the file cannot be read, it returns an error, otherwise it returns the
 // PID as a string.
-func getDbusPid() (string, error) {
-	if err := sutils.FileWaitForFileExist(fileOmxDbusPid, 500); err != nil {
-		return "", err
-	}
-	return sutils.FileWaitContentsAndRead(fileOmxDbusPid, 500)
+func (p *Player) getDbusPid() (string, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, p.config.DbusFileTimeout)
+	defer cancel()
+	return waitDbusFile(ctx, p.fileOmxDbusPid)
 }
 
-func dbusIsRunning() bool {
-	var pid int
-	if _pid, err := getDbusPid(); err != nil {
+// dbusIsRunning reports whether the D-Bus daemon named in the PID file is
+// alive. It does not wait for the file to appear.
+func (p *Player) dbusIsRunning() bool {
+	contents, err := os.ReadFile(p.fileOmxDbusPid)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
 		return false
-	} else {
-		if pid, err = strconv.Atoi(_pid); err != nil {
-			return false
-		}
 	}
 	return sutils.IsProcessAlive(pid)
 }
 
 // removeDbusFiles removes the files that OMXPlayer creates containing the D-Bus
 // path and PID. This ensures that when the path and PID are read in, the new
-// files are read instead of the old ones.
-func removeDbusFiles() {
-	if !dbusIsRunning() {
-		sutils.FileremoveFile(fileOmxDbusPath)
-		sutils.FileremoveFile(fileOmxDbusPid)
+// files are read instead of the old ones. The files are left alone while the
+// D-Bus daemon is alive, since other instances may still be using it.
+func (p *Player) removeDbusFiles() {
+	if !p.dbusIsRunning() {
+		sutils.FileremoveFile(p.fileOmxDbusPath)
+		sutils.FileremoveFile(p.fileOmxDbusPid)
 	}
 }
 
-// execOmxplayer starts a new OMXPlayer process and tells it to pause the video
-// by passing a "p" on standard input.
-func execOmxplayer(url string, args ...string) (cmd *exec.Cmd, err error) {
+// execOmxplayer starts a new OMXPlayer process with environment env and
+// returns it with its standard input, to send it keys.
+func execOmxplayer(binary string, env []string, url string, args ...string) (cmd *exec.Cmd, keys io.WriteCloser, err error) {
 	//	log.Debug("omxplayer: starting omxplayer process")
 
 	args = append(args, url)
 
-	cmd = exec.Command(exeOxmPlayer, args...)
-	cmd.Stdin = strings.NewReader(keyPause)
-	err = cmd.Start()
+	cmd = exec.Command(binary, args...)
+	cmd.Env = env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if keys, err = cmd.StdinPipe(); err != nil {
+		return nil, nil, startError(url, err)
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, nil, startError(url, err)
+	}
 	return
 }
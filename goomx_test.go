@@ -0,0 +1,232 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// eventTimeout bounds every wait for player events.
+const eventTimeout = 10 * time.Second
+
+// harness is shared by the tests; every player has D-Bus files of its own.
+var (
+	harness    *goomxtest.Harness
+	harnessErr error
+)
+
+func TestMain(m *testing.M) {
+	harness, harnessErr = goomxtest.New("")
+	code := m.Run()
+	if harness != nil {
+		harness.Close()
+	}
+	os.Exit(code)
+}
+
+// newPlayer returns a player driving the fake omxplayer with script s,
+// configured further by configure if it is not nil, and closes it at the end
+// of the test.
+func newPlayer(t *testing.T, s goomxtest.Script, configure func(*goomx.PlayerConfig)) *goomx.Player {
+	t.Helper()
+	if harnessErr != nil {
+		t.Skip("no fake omxplayer: ", harnessErr)
+	}
+	cfg := harness.Config(s)
+	if configure != nil {
+		configure(&cfg)
+	}
+	p, err := goomx.NewPlayerWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+		defer cancel()
+		if err := p.Close(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+	return p
+}
+
+// clips creates a file for each name in a temporary directory and returns
+// their paths.
+func clips(t *testing.T, names ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+		if err := os.WriteFile(paths[i], []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+// collect returns the next n events of type typ from events, failing the
+// test if they do not arrive in time.
+func collect(t *testing.T, events <-chan goomx.PlayerEvent, typ goomx.EventType, n int) []goomx.PlayerEvent {
+	t.Helper()
+	var got []goomx.PlayerEvent
+	timeout := time.After(eventTimeout)
+	for len(got) < n {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatalf("events closed after %d %s events", len(got), typ)
+			}
+			if ev.Type == typ {
+				got = append(got, ev)
+			}
+		case <-timeout:
+			t.Fatalf("got %d %s events, want %d", len(got), typ, n)
+		}
+	}
+	return got
+}
+
+// paths returns the base names of the paths of events.
+func paths(events []goomx.PlayerEvent) []string {
+	names := make([]string, len(events))
+	for i, ev := range events {
+		names[i] = filepath.Base(ev.Path)
+	}
+	return names
+}
+
+func equal(a, b []string) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func TestPlaylistAdvance(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: 200 * time.Millisecond}, nil)
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4", "c.mp4"))
+	p.Play()
+
+	started := collect(t, events, goomx.EventStarted, 4)
+	if got, want := paths(started), []string{"a.mp4", "b.mp4", "c.mp4", "a.mp4"}; !equal(got, want) {
+		t.Errorf("started %v, want %v", got, want)
+	}
+	for i, ev := range started[:3] {
+		if ev.Index != i {
+			t.Errorf("%s started at index %d, want %d", ev.Path, ev.Index, i)
+		}
+	}
+}
+
+func TestPlaylistFinishedEvents(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: 200 * time.Millisecond}, nil)
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4"))
+	p.Play()
+
+	for _, ev := range collect(t, events, goomx.EventFinished, 2) {
+		if ev.Err != nil || ev.Interrupted || ev.ExitCode != 0 {
+			t.Errorf("%s finished with err %v, interrupted %t, exit code %d", ev.Path, ev.Err, ev.Interrupted, ev.ExitCode)
+		}
+		if ev.StartTime.IsZero() || ev.Duration <= 0 {
+			t.Errorf("%s finished with start time %v and duration %v", ev.Path, ev.StartTime, ev.Duration)
+		}
+	}
+}
+
+func TestSeekVideos(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4", "c.mp4"))
+	p.Play()
+	collect(t, events, goomx.EventStarted, 1)
+
+	next, ok := p.SeekVideos(2)
+	if !ok || filepath.Base(next) != "c.mp4" {
+		t.Fatalf("SeekVideos(2) = %q, %t, want c.mp4", next, ok)
+	}
+	if ev := collect(t, events, goomx.EventFinished, 1)[0]; !ev.Interrupted {
+		t.Errorf("a.mp4 finished without being interrupted")
+	}
+	if ev := collect(t, events, goomx.EventStarted, 1)[0]; filepath.Base(ev.Path) != "c.mp4" {
+		t.Errorf("started %s after seeking, want c.mp4", ev.Path)
+	}
+	if playing, ok := p.GetPlaying(); !ok || filepath.Base(playing) != "c.mp4" {
+		t.Errorf("GetPlaying() = %q, %t, want c.mp4", playing, ok)
+	}
+}
+
+func TestClose(t *testing.T) {
+	if harnessErr != nil {
+		t.Skip("no fake omxplayer: ", harnessErr)
+	}
+	p, err := goomx.NewPlayerWithConfig(harness.Config(goomxtest.Script{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4"))
+	p.Play()
+	collect(t, events, goomx.EventStarted, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ev := collect(t, events, goomx.EventFinished, 1)[0]; !ev.Interrupted {
+		t.Errorf("clip finished without being interrupted by Close")
+	}
+	for ev := range events {
+		t.Errorf("event %s after Close", ev.Type)
+	}
+	if _, ok := <-p.Events(); ok {
+		t.Errorf("subscription after Close is open")
+	}
+	if p.IsRunning() {
+		t.Errorf("player is running after Close")
+	}
+	if err := p.Close(ctx); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestEventSubscribers(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: 200 * time.Millisecond}, nil)
+	first, second := p.Events(), p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4"))
+	p.Play()
+
+	collect(t, first, goomx.EventStarted, 1)
+	collect(t, second, goomx.EventStarted, 1)
+	p.Unsubscribe(second)
+	for range second { // drains the events buffered before Unsubscribe, then ends
+	}
+	collect(t, first, goomx.EventFinished, 1)
+}
+
+func TestMissingFileSkipped(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: 200 * time.Millisecond}, func(cfg *goomx.PlayerConfig) {
+		cfg.MissingFileDelay = 10 * time.Millisecond
+	})
+	events := p.Events()
+	list := clips(t, "a.mp4")
+	missing := filepath.Join(filepath.Dir(list[0]), "missing.mp4")
+	p.ConfigureNewPlaylist([]string{missing, list[0]})
+	p.Play()
+
+	ev := collect(t, events, goomx.EventSkipped, 1)[0]
+	if ev.Path != missing {
+		t.Errorf("skipped %s, want %s", ev.Path, missing)
+	}
+	if ev := collect(t, events, goomx.EventStarted, 1)[0]; ev.Path != list[0] {
+		t.Errorf("started %s, want %s", ev.Path, list[0])
+	}
+}
@@ -0,0 +1,252 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sonnt85/gosutils/slogrus"
+)
+
+// Backend is a media player program that plays one playlist entry per
+// process. The player starts a Session for each entry and routes its control
+// methods (CmdPause, CmdSeek, CmdVolume, Position, ...) to it.
+type Backend interface {
+	// Name identifies the backend in logs.
+	Name() string
+	// Start launches the program for file with args and environment env
+	// and returns once the session accepts commands or ctx is done.
+	Start(ctx context.Context, file string, args, env []string) (Session, error)
+}
+
+// Session controls one running player process. Positions and durations are in
+// microseconds and volumes are linear (1.0 is 100%), as with omxplayer.
+type Session interface {
+	// Wait waits for the process to exit and returns its exit error.
+	Wait() error
+	// Kill kills the process.
+	Kill() error
+	Quit(ctx context.Context) error
+	Pause(ctx context.Context) error
+	Play(ctx context.Context) error
+	PlayPause(ctx context.Context) error
+	// PlaybackStatus returns "Playing" or "Paused".
+	PlaybackStatus(ctx context.Context) (string, error)
+	Seek(ctx context.Context, offset int64) (int64, error)
+	SetPosition(ctx context.Context, path string, position int64) (int64, error)
+	// Volume returns the volume, setting it first if an argument is given.
+	Volume(ctx context.Context, volume ...float64) (float64, error)
+	Position(ctx context.Context) (int64, error)
+	Duration(ctx context.Context) (int64, error)
+	ListAudio(ctx context.Context) ([]string, error)
+	ListSubtitles(ctx context.Context) ([]string, error)
+}
+
+// SetBackend selects the program that plays the following playlist entries.
+// nil selects omxplayer, which the player drives over D-Bus itself and which
+// is the only backend supporting gapless mode and omxplayer specific methods
+// such as CmdSetLayer; with other backends those fail with ErrNotSupported.
+// The args given when creating the player are passed to the backend.
+func (p *Player) SetBackend(b Backend) {
+	p.busMu.Lock()
+	defer p.busMu.Unlock()
+	p.backend = b
+}
+
+// detectBackend returns the backend new players start with: nil (omxplayer)
+// if the omxplayer binary is installed, as on 32-bit Raspberry Pi OS up to
+// Bullseye, otherwise mpv or VLC, whichever is found first. args are the
+// player's omxplayer args; as the other programs do not understand them,
+// omxplayer is kept if there are any.
+func detectBackend(omxplayer string, args []string) Backend {
+	if _, err := exec.LookPath(omxplayer); err == nil {
+		slogrus.Print("Backend: ", omxplayer)
+		return nil
+	}
+	if len(args) > 0 {
+		slogrus.Print("Backend: ", omxplayer, " not found, kept for its args ", args)
+		return nil
+	}
+	if _, err := exec.LookPath(exeMpv); err == nil {
+		slogrus.Print("Backend: ", omxplayer, " not found, using ", exeMpv)
+		return &MpvBackend{}
+	}
+	if _, err := exec.LookPath(exeCvlc); err == nil {
+		slogrus.Print("Backend: ", omxplayer, " not found, using ", exeCvlc)
+		return &VlcBackend{}
+	}
+	slogrus.Print("Backend: no player found, using ", exeOxmPlayer)
+	return nil
+}
+
+// getBackend returns the backend that plays the next entry.
+func (p *Player) getBackend() Backend {
+	p.busMu.RLock()
+	defer p.busMu.RUnlock()
+	if p.backend == nil {
+		return &omxBackend{p: p}
+	}
+	return p.backend
+}
+
+// clipStarter is implemented by backends that apply the settings of a
+// playlist entry, such as its start position, when starting it, rather than
+// through the Session once it runs.
+type clipStarter interface {
+	startClip(ctx context.Context, file FilePlay, args []string) (Session, error)
+}
+
+// clipArgs returns the player's args followed by those of file.
+func (p *Player) clipArgs(file FilePlay) []string {
+	args := make([]string, 0, len(p.config.Args)+len(file.args))
+	return append(append(args, p.config.Args...), file.args...)
+}
+
+// setSession sets the session control methods are routed to, nil when
+// nothing is playing.
+func (p *Player) setSession(s Session) {
+	p.busMu.Lock()
+	defer p.busMu.Unlock()
+	p.session = s
+	p.bus = nil
+	if omx, ok := s.(*omxSession); ok {
+		p.bus = omx.bus
+	}
+}
+
+// currentSession returns the session that is currently playing, or nil.
+func (p *Player) currentSession() Session {
+	p.busMu.RLock()
+	defer p.busMu.RUnlock()
+	return p.session
+}
+
+// control returns the current session for operation op or an ErrNotRunning
+// error.
+func (p *Player) control(op string) (Session, error) {
+	if p.ctx.Err() != nil {
+		return nil, &PlayerError{Op: op, Err: ErrClosed}
+	}
+	if s := p.currentSession(); s != nil {
+		return s, nil
+	}
+	return nil, &PlayerError{Op: op, Err: ErrNotRunning}
+}
+
+// playSession plays filePlay with backend b and returns once it has ended,
+// with the error reported in its EventFailed or EventFinished. stopped
+// reports that it was stopped on request or at its maximum play time rather
+// than ending on its own. Every backend, omxplayer included, goes through it,
+// so the stop, play limit and stall handling are the same for all.
+func (p *Player) playSession(b Backend, filePlay FilePlay) (stopped bool, err error) {
+	args := p.clipArgs(filePlay)
+	startCtx, cancel := context.WithTimeout(p.ctx, p.config.ReadyTimeout)
+	var s Session
+	cs, setsClip := b.(clipStarter)
+	if setsClip {
+		s, err = cs.startClip(startCtx, filePlay, args)
+	} else {
+		s, err = b.Start(startCtx, filePlay.location, args, p.processEnv())
+	}
+	cancel()
+	if err != nil {
+		slogrus.Printf("Can not start %s: %s - %s\n", b.Name(), filePlay.pathFile, err.Error())
+		p.emit(PlayerEvent{Type: EventFailed, Path: filePlay.pathFile, Err: err})
+		return false, err
+	}
+	p.setSession(s)
+	_, omx := s.(*omxSession)
+	if !omx { // omxplayer's readiness follows its D-Bus name
+		p.ready.set(true)
+	}
+	// the clip's settings are in place before anyone hears of it
+	if filePlay.start > 0 && !setsClip {
+		if _, err := s.SetPosition(p.ctx, pathMpris, filePlay.start.Microseconds()); err != nil {
+			slogrus.Error("Can not set start position", err)
+		}
+	}
+	if err := p.applyVolume(p.ctx, s, filePlay); err != nil {
+		slogrus.Error("Can not Set Volume", err)
+	}
+	startTime := time.Now()
+	p.setNowPlaying(filePlay, startTime)
+	p.emit(PlayerEvent{Type: EventStarted, Path: filePlay.pathFile, StartTime: startTime})
+	p.condStopViewPicture.SetThenSendSignal(true)
+
+	var interrupted atomic.Bool
+	var stalled atomic.Pointer[error]
+	var limited atomic.Bool // stopped at its maximum play time
+	var clip sync.WaitGroup // waited for before the next clip starts
+	ctx, cancelPlay := context.WithCancel(p.ctx)
+	clip.Add(2)
+	go func() {
+		defer clip.Done()
+		limit, stopLimit := playLimit(filePlay)
+		defer stopLimit()
+		select {
+		case <-p.condStop.TestThenWaitSignalIfMatch(false, true): //force kill
+			interrupted.Store(true)
+			s.Kill()
+		case <-limit:
+			limited.Store(true)
+			s.Kill()
+		case <-ctx.Done():
+			p.condStop.Signal()
+			if p.ctx.Err() != nil { // player is closing
+				interrupted.Store(true)
+				s.Kill()
+			}
+		}
+	}()
+	go func() {
+		defer clip.Done()
+		if err := p.watchStall(ctx, s, filePlay.pathFile); err != nil {
+			stalled.Store(&err)
+			s.Kill()
+		}
+	}()
+	p.condStart.SetThenSendBroadcast(true)
+	err = s.Wait()
+	cancelPlay()
+	clip.Wait()
+	slogrus.Print("Finish play ", filePlay.pathFile)
+
+	p.ready.set(false)
+	p.setSession(nil)
+	p.setNowPlaying(FilePlay{}, time.Time{})
+	// showing pictures between gapless clips would flash the screen
+	if !p.hasPreloaded() {
+		p.condStartViewPicture.SetThenSendSignal(true)
+	}
+	p.condStop.SetThenSendBroadcast(false)
+	p.condStart.Set(false)
+
+	exitCode := 0
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		exitCode = ee.ExitCode()
+	}
+	stopped = interrupted.Load() || limited.Load() || (omx && exitCode == omxExitQuit)
+	if e := stalled.Load(); e != nil {
+		err, stopped = *e, false
+	} else if stopped {
+		err = nil // killed or quit on request, not a failure
+	} else if ee != nil {
+		err = newExitError(filePlay.pathFile, ee.ProcessState)
+	}
+	p.emit(PlayerEvent{
+		Type:        EventFinished,
+		Path:        filePlay.pathFile,
+		StartTime:   startTime,
+		Duration:    time.Since(startTime),
+		ExitCode:    exitCode,
+		Interrupted: interrupted.Load(),
+		Err:         err,
+	})
+	return stopped, err
+}
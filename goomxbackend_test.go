@@ -0,0 +1,201 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// fakeBackend is a Backend whose sessions play for d and record how they
+// were started.
+type fakeBackend struct {
+	d      time.Duration
+	mu     sync.Mutex
+	starts []fakeStart
+}
+
+type fakeStart struct {
+	file      string
+	args, env []string
+}
+
+func (b *fakeBackend) Name() string { return "fake" }
+
+func (b *fakeBackend) Start(ctx context.Context, file string, args, env []string) (goomx.Session, error) {
+	b.mu.Lock()
+	b.starts = append(b.starts, fakeStart{file: file, args: args, env: env})
+	b.mu.Unlock()
+	s := &fakeSession{exited: make(chan struct{})}
+	time.AfterFunc(b.d, func() { s.Kill() })
+	return s, nil
+}
+
+func (b *fakeBackend) started() []fakeStart {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.starts)
+}
+
+// fakeSession is a Session that ends when killed or quit.
+type fakeSession struct {
+	once   sync.Once
+	exited chan struct{}
+	mu     sync.Mutex
+	paused bool
+}
+
+func (s *fakeSession) Wait() error {
+	<-s.exited
+	return nil
+}
+
+func (s *fakeSession) Kill() error {
+	s.once.Do(func() { close(s.exited) })
+	return nil
+}
+
+func (s *fakeSession) Quit(ctx context.Context) error { return s.Kill() }
+
+func (s *fakeSession) setPaused(paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+	return nil
+}
+
+func (s *fakeSession) Pause(ctx context.Context) error { return s.setPaused(true) }
+func (s *fakeSession) Play(ctx context.Context) error  { return s.setPaused(false) }
+
+func (s *fakeSession) PlayPause(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = !s.paused
+	return nil
+}
+
+func (s *fakeSession) PlaybackStatus(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused {
+		return "Paused", nil
+	}
+	return "Playing", nil
+}
+
+func (s *fakeSession) Seek(ctx context.Context, offset int64) (int64, error) { return offset, nil }
+func (s *fakeSession) SetPosition(ctx context.Context, path string, position int64) (int64, error) {
+	return position, nil
+}
+func (s *fakeSession) Volume(ctx context.Context, volume ...float64) (float64, error) { return 1, nil }
+func (s *fakeSession) Position(ctx context.Context) (int64, error)                    { return 0, nil }
+func (s *fakeSession) Duration(ctx context.Context) (int64, error)                    { return 0, nil }
+func (s *fakeSession) ListAudio(ctx context.Context) ([]string, error)                { return nil, nil }
+func (s *fakeSession) ListSubtitles(ctx context.Context) ([]string, error)            { return nil, nil }
+
+func TestBackend(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, func(cfg *goomx.PlayerConfig) {
+		cfg.Args = []string{"--no-osd"}
+		cfg.Env = append(cfg.Env, "GOOMX_TEST=backend")
+	})
+	b := &fakeBackend{d: 300 * time.Millisecond}
+	p.SetBackend(b)
+	events := p.Events()
+	list := clips(t, "a.mp4", "b.mp4")
+	p.ConfigureNewPlaylist(list)
+	p.Play()
+
+	collect(t, events, goomx.EventStarted, 1)
+	if err := p.CmdPause(); err != nil {
+		t.Errorf("CmdPause: %v", err)
+	}
+	if status, err := p.CmdPlaybackStatus(); err != nil || status != "Paused" {
+		t.Errorf("CmdPlaybackStatus() = %q, %v, want Paused", status, err)
+	}
+	if err := p.CmdSetLayer(1); !errors.Is(err, goomx.ErrNotSupported) {
+		t.Errorf("CmdSetLayer: %v, want ErrNotSupported", err)
+	}
+	collect(t, events, goomx.EventFinished, 2)
+
+	starts := b.started()
+	if len(starts) < 2 || starts[0].file != list[0] || starts[1].file != list[1] {
+		t.Fatalf("started %+v, want a.mp4 then b.mp4", starts)
+	}
+	if !slices.Contains(starts[0].args, "--no-osd") {
+		t.Errorf("args %v lack the player's args", starts[0].args)
+	}
+	if !slices.Contains(starts[0].env, "GOOMX_TEST=backend") {
+		t.Errorf("environment lacks PlayerConfig.Env")
+	}
+	if !slices.ContainsFunc(starts[0].env, func(kv string) bool { return strings.HasPrefix(kv, "DISPLAY=") }) {
+		t.Errorf("environment lacks DISPLAY")
+	}
+}
+
+// TestMpvBackendEnv starts a stand-in for mpv that writes its environment to
+// a file and exits.
+func TestMpvBackendEnv(t *testing.T) {
+	dir := t.TempDir()
+	mpv := filepath.Join(dir, "mpv")
+	out := filepath.Join(dir, "env")
+	if err := os.WriteFile(mpv, []byte("#!/bin/sh\nenv > \"$GOOMX_ENV_OUT\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+	b := &goomx.MpvBackend{Binary: mpv}
+	if _, err := b.Start(ctx, clips(t, "a.mp4")[0], nil, []string{"GOOMX_ENV_OUT=" + out, "DISPLAY=:7"}); err == nil {
+		t.Fatal("Start succeeded without an IPC socket")
+	}
+	env, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal("mpv did not get the environment: ", err)
+	}
+	if !strings.Contains(string(env), "DISPLAY=:7\n") {
+		t.Errorf("mpv environment\n%s\nlacks DISPLAY=:7", env)
+	}
+}
+
+// TestBackendFallback creates players without omxplayer installed and with
+// a stand-in for mpv that leaves a mark when started.
+func TestBackendFallback(t *testing.T) {
+	dir := t.TempDir()
+	mark := filepath.Join(dir, "mark")
+	if err := os.WriteFile(filepath.Join(dir, "mpv"), []byte("#!/bin/sh\ntouch \"$GOOMX_MARK\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("GOOMX_MARK", mark)
+	missing := filepath.Join(dir, "omxplayer")
+
+	for _, args := range [][]string{{"--no-osd"}, nil} {
+		os.Remove(mark)
+		p := newPlayer(t, goomxtest.Script{}, func(cfg *goomx.PlayerConfig) {
+			cfg.OmxplayerBinary = missing
+			cfg.Args = args
+		})
+		events := p.Events()
+		p.ConfigureNewPlaylist(clips(t, "a.mp4"))
+		p.Play()
+		failed := collect(t, events, goomx.EventFailed, 1)[0]
+		p.Stop()
+		_, err := os.Stat(mark)
+		if args != nil {
+			if !errors.Is(failed.Err, goomx.ErrBinaryNotFound) || err == nil {
+				t.Errorf("with args %v: failed with %v, mpv started %t; want omxplayer kept", args, failed.Err, err == nil)
+			}
+		} else if err != nil {
+			t.Errorf("without args mpv was not started: %v", failed.Err)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+//go:build linux
+
+package goomx
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidConfig is returned by NewPlayerWithConfig for a PlayerConfig that
+// fails validation.
+var ErrInvalidConfig = errors.New("invalid player config")
+
+// PlayerConfig configures a player created with NewPlayerWithConfig. Start from
+// DefaultPlayerConfig and change what differs.
+type PlayerConfig struct {
+	// DbusName is the D-Bus name omxplayer registers under. If empty, a
+	// unique name is generated.
+	DbusName string
+	// Display is the display omxplayer plays on (--display), e.g. 2 for
+	// HDMI0 and 7 for HDMI1 on a Pi 4. 0 leaves the choice to omxplayer.
+	Display int
+	// DbusAddressFile and DbusPidFile are the files omxplayer writes its
+	// D-Bus address and PID to, for an omxplayer that does not use its usual
+	// files; see Player.SetUser for those. DbusPidFile defaults to
+	// DbusAddressFile with ".pid" appended.
+	DbusAddressFile string
+	DbusPidFile     string
+	// Env are environment variables, "KEY=value", added to the environment
+	// of the omxplayer and omxiv processes of this player only, e.g.
+	// "DISPLAY=:1". DISPLAY is :0 unless set here or in the environment.
+	Env []string
+	// OmxplayerBinary and OmxivBinary are the executables for videos and for
+	// the default pictures, looked up in PATH unless they contain a slash.
+	OmxplayerBinary string
+	OmxivBinary     string
+	// Args are passed to the player's backend for every playlist entry.
+	Args []string
+	// OmxivArgs are passed to omxiv before the picture path.
+	OmxivArgs []string
+	// OmxivSlideTime is how long each picture is shown when the default
+	// pictures are a directory (omxiv -t, whole seconds).
+	OmxivSlideTime time.Duration
+	// InitialVolume is the volume set on every new omxplayer process until
+	// CmdVolume changes it, linear with 1.0 for 100%.
+	InitialVolume float64
+	// ReadyTimeout bounds the wait for a started process to accept commands.
+	ReadyTimeout time.Duration
+	// DbusFileTimeout bounds the wait for omxplayer to write its D-Bus files.
+	DbusFileTimeout time.Duration
+	// MissingFileDelay is the pause after skipping a playlist entry that does
+	// not exist, so a playlist of missing files does not spin.
+	MissingFileDelay time.Duration
+	// CrashBackoff is the pause after a playlist entry failed, doubled for
+	// each further failure in a row up to CrashBackoffMax. 0 disables it.
+	CrashBackoff    time.Duration
+	CrashBackoffMax time.Duration
+	// QuarantineAfter is how many failures in a row quarantine an entry, so
+	// it is skipped, for QuarantineFor or, if 0, until ClearQuarantine. 0
+	// never quarantines.
+	QuarantineAfter int
+	QuarantineFor   time.Duration
+	// StallTimeout is how long the position may stay unchanged while the
+	// player reports "Playing", or the player may fail to answer, before the
+	// process is killed and the playlist moves on. 0 disables the watchdog.
+	StallTimeout time.Duration
+	// StreamArgs are passed to omxplayer for network streams, and
+	// LiveStreamArgs in addition for live ones (RTSP, RTMP, RTP, UDP and HLS).
+	StreamArgs     []string
+	LiveStreamArgs []string
+	// StreamRetries is how many times in a row a stream that fails or
+	// stalls, or a live stream that ends, is restarted, StreamRetryDelay
+	// apart, before the playlist moves on.
+	StreamRetries    int
+	StreamRetryDelay time.Duration
+	// PlaybackMode is the initial playback mode, see SetPlaybackMode.
+	PlaybackMode PlaybackMode
+	// ShuffleSeed seeds the random order of ModeShuffle. 0 seeds it from the
+	// clock.
+	ShuffleSeed int64
+}
+
+// DefaultPlayerConfig returns the configuration NewPlayer and
+// NewPlayerInstance use.
+func DefaultPlayerConfig() PlayerConfig {
+	return PlayerConfig{
+		OmxplayerBinary:  exeOxmPlayer,
+		OmxivBinary:      exeOmxiv,
+		OmxivArgs:        []string{"-a", "center", "--transition", "blend", "--duration", "3000"},
+		OmxivSlideTime:   3 * time.Second,
+		InitialVolume:    0.03,
+		ReadyTimeout:     10 * time.Second,
+		DbusFileTimeout:  time.Second,
+		MissingFileDelay: 500 * time.Millisecond,
+		CrashBackoff:     time.Second,
+		CrashBackoffMax:  time.Minute,
+		QuarantineAfter:  3,
+		QuarantineFor:    time.Hour,
+		StallTimeout:     30 * time.Second,
+		StreamArgs:       []string{"--timeout", "10"},
+		LiveStreamArgs:   []string{"--live"},
+		StreamRetries:    3,
+		StreamRetryDelay: 2 * time.Second,
+	}
+}
+
+// Validate reports the first invalid setting of c as an ErrInvalidConfig
+// error.
+func (c *PlayerConfig) Validate() error {
+	invalid := func(format string, a ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, fmt.Sprintf(format, a...))
+	}
+	switch {
+	case c.DbusName != "" && !validBusName(c.DbusName):
+		return invalid("D-Bus name %q is not a valid well-known name", c.DbusName)
+	case c.Display < 0:
+		return invalid("display %d is negative", c.Display)
+	case c.DbusAddressFile == "" && c.DbusPidFile != "":
+		return invalid("D-Bus PID file %s without an address file", c.DbusPidFile)
+	case c.OmxplayerBinary == "":
+		return invalid("omxplayer binary is empty")
+	case c.OmxivBinary == "":
+		return invalid("omxiv binary is empty")
+	case c.OmxivSlideTime < time.Second:
+		return invalid("omxiv slide time %s is shorter than a second", c.OmxivSlideTime)
+	case c.InitialVolume < 0:
+		return invalid("initial volume %g is negative", c.InitialVolume)
+	case c.ReadyTimeout <= 0:
+		return invalid("ready timeout %s is not positive", c.ReadyTimeout)
+	case c.DbusFileTimeout <= 0:
+		return invalid("D-Bus file timeout %s is not positive", c.DbusFileTimeout)
+	case c.MissingFileDelay < 0:
+		return invalid("missing file delay %s is negative", c.MissingFileDelay)
+	case c.CrashBackoff < 0:
+		return invalid("crash backoff %s is negative", c.CrashBackoff)
+	case c.CrashBackoffMax < c.CrashBackoff:
+		return invalid("crash backoff maximum %s is less than crash backoff %s", c.CrashBackoffMax, c.CrashBackoff)
+	case c.QuarantineAfter < 0:
+		return invalid("quarantine threshold %d is negative", c.QuarantineAfter)
+	case c.QuarantineFor < 0:
+		return invalid("quarantine duration %s is negative", c.QuarantineFor)
+	case c.StallTimeout < 0:
+		return invalid("stall timeout %s is negative", c.StallTimeout)
+	case c.StreamRetries < 0:
+		return invalid("stream retries %d is negative", c.StreamRetries)
+	case c.StreamRetryDelay < 0:
+		return invalid("stream retry delay %s is negative", c.StreamRetryDelay)
+	case c.PlaybackMode < ModeRepeatAll || c.PlaybackMode > ModeShuffle:
+		return invalid("unknown playback mode %d", c.PlaybackMode)
+	}
+	for _, kv := range c.Env {
+		if k, _, ok := strings.Cut(kv, "="); !ok || k == "" {
+			return invalid("environment variable %q is not KEY=value", kv)
+		}
+	}
+	return nil
+}
+
+// validBusName reports whether name is a valid D-Bus well-known bus name.
+func validBusName(name string) bool {
+	if len(name) > 255 || strings.HasPrefix(name, ":") {
+		return false
+	}
+	elements := strings.Split(name, ".")
+	if len(elements) < 2 {
+		return false
+	}
+	for _, e := range elements {
+		if e == "" || (e[0] >= '0' && e[0] <= '9') {
+			return false
+		}
+		for _, r := range e {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NewPlayerWithConfig returns a new, independent Player configured by cfg,
+// like NewPlayerInstance.
+func NewPlayerWithConfig(cfg PlayerConfig) (player *Player, err error) {
+	if err = cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.Args = append([]string(nil), cfg.Args...)
+	cfg.Env = append([]string(nil), cfg.Env...)
+	cfg.OmxivArgs = append([]string(nil), cfg.OmxivArgs...)
+	cfg.StreamArgs = append([]string(nil), cfg.StreamArgs...)
+	cfg.LiveStreamArgs = append([]string(nil), cfg.LiveStreamArgs...)
+	playersMu.Lock()
+	defer playersMu.Unlock()
+	if cfg.DbusName == "" {
+		cfg.DbusName = nextDbusName()
+	}
+	return newPlayer(cfg)
+}
+
+// omxivArgs returns the omxiv arguments to show picspath, a file or, if dir,
+// a directory shown as a slideshow.
+func (p *Player) omxivArgs(picspath string, dir bool) []string {
+	args := make([]string, 0, len(p.config.OmxivArgs)+3)
+	if dir {
+		args = append(args, "-t", strconv.Itoa(int(p.config.OmxivSlideTime/time.Second)))
+	}
+	args = append(args, p.config.OmxivArgs...)
+	return append(args, picspath)
+}
@@ -1,13 +1,19 @@
-//go:build linux && arm
+//go:build linux
 
 package goomx
 
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
 	"os/exec"
 	"path/filepath"
-	"strings"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -72,6 +78,7 @@ const (
 	cmdGetSource            = ifaceOmxPlayer + ".GetSource"
 	cmdOpenUri              = ifaceOmxPlayer + ".OpenUri"
 	cmdRaise                = ifaceProps + ".Raise"
+	cmdSetLayer             = ifaceOmxPlayer + ".SetLayer"
 )
 
 // The Player struct provides access to all of omxplayer's D-Bus methods.
@@ -81,15 +88,32 @@ type CommonOmx struct {
 
 type FilePlay struct {
 	pathFile     string
+	location     string // what is played: pathFile, or its path if it is a file URL
 	isStreamLink bool
 	args         []string
+	start        time.Duration // position to start at
+	maxPlay      time.Duration // play time limit, 0 for none
+	volume       float64       // 0 for the player's volume
+	loops        int           // further plays in a row
 }
 type Player struct {
-	command       *exec.Cmd
-	bus           dbus.BusObject
-	argsOmx       []string
-	currentVolume float64
+	bus             dbus.BusObject
+	busMu           sync.RWMutex
+	dbusName        string
+	user            string
+	home            string
+	fileOmxDbusPath string
+	fileOmxDbusPid  string
+	dbusUser        string // USER omxplayer names its D-Bus files after
+	customDbusFiles bool   // set by SetDbusFiles
+	config          PlayerConfig
+	volumeMu        sync.Mutex
+	currentVolume   float64 // set by CmdVolume, guarded by volumeMu
 	*goring.EventLinkedList[string]
+	// CommandKeysBuffer was the standard input of omxplayer.
+	//
+	// Deprecated: keys written here never reach omxplayer; Quit sends the
+	// quit key itself.
 	CommandKeysBuffer        *bytes.Buffer
 	startedViewPicture       bool
 	playingFile              chan FilePlay
@@ -99,25 +123,86 @@ type Player struct {
 	condStartViewPicture     *gosyncutils.EventOpject[bool]
 	condStopViewPicture      *gosyncutils.EventOpject[bool]
 	condFinishCurrentPlaying *gosyncutils.EventOpject[struct{}]
-
-	condStart  *gosyncutils.EventOpject[bool]
-	ctx        context.Context
-	CancelFunc context.CancelFunc
+	condPlaylist             *gosyncutils.EventOpject[struct{}] // broadcast when the playlist is replaced
+
+	condStart    *gosyncutils.EventOpject[bool]
+	ready        *readyFlag
+	backend      Backend // nil for omxplayer, see getBackend
+	session      Session
+	queueMu      sync.Mutex
+	mode         PlaybackMode // guarded by queueMu, as are the following
+	queueHold    bool         // the current entry is the next one played
+	pos          int          // index of the current entry, see cursor
+	shuffleRand  *rand.Rand
+	shuffleBag   []int // indexes left in the shuffle order
+	shuffleLen   int   // playlist length the shuffle order is for
+	scheduler    Scheduler
+	played       int        // entries started since the playlist was restarted
+	interrupts   []FilePlay // pending PlayInterrupt items
+	resume       []FilePlay // resume the interrupted clip after them
+	interrupting bool       // an interrupt is pending or playing
+	ctx          context.Context
+	CancelFunc   context.CancelFunc
+	wg           sync.WaitGroup
+	closeOnce    sync.Once
+
+	eventsMu     sync.Mutex
+	subscribers  map[<-chan PlayerEvent]chan PlayerEvent
+	playing      FilePlay
+	playingSince time.Time
+	proofOfPlay  *ProofOfPlayRecorder
+	eventsClosed bool             // set by Close, no more records are queued
+	popQueue     []proofOfPlayJob // proof-of-play records to write, in order
+	popWriting   bool             // a goroutine is writing popQueue
+	recording    sync.WaitGroup   // the goroutine writing popQueue
+
+	gaplessMu      sync.Mutex
+	gapless        bool
+	gaplessPreroll time.Duration
+	gaplessLayer   int
+	preloaded      *omxProcess
+
+	crashMu     sync.Mutex
+	crashes     map[string]*crashRecord // by playlist entry
+	crashStreak int                     // failures in a row, of any entry
+
+	itemsMu    sync.Mutex
+	items      map[string]PlaylistItem // settings by path
+	loopGen    atomic.Uint64           // bumped when the user moves through the playlist, ending repetitions
+	uriSchemes []string                // reported by omxplayer, guarded by itemsMu
 }
 
 var Gplayer *Player
 
 func (p *Player) SeekVideos(n int) (retfile string, ok bool) {
-	if p.Length() != 0 && p.enablePlay.Get() {
-		p.enablePlay.Set(false)
-		p.condStop.SetThenSendBroadcast(true) // stop to play next video
-		retfile, err := p.SeekWait(n)
-		p.SeekWait(-p.SeekStep.Get())
-		p.enablePlay.Set(true)
-		return retfile, err == nil
-	} else {
+	retfile, err := p.SeekVideosContext(context.Background(), n)
+	return retfile, err == nil
+}
+
+// SeekVideosContext stops the current file and moves n entries through the
+// playlist, returning the file that will be played next. It fails with
+// ErrNotRunning if playback is stopped.
+func (p *Player) SeekVideosContext(ctx context.Context, n int) (retfile string, err error) {
+	if err = ctx.Err(); err != nil {
 		return
 	}
+	if !p.enablePlay.Get() {
+		return "", &PlayerError{Op: "seek", Err: ErrNotRunning}
+	}
+	p.enablePlay.Set(false)
+	p.loopGen.Add(1)
+	p.condStop.SetThenSendBroadcast(true) // stop to play next video
+	p.queueMu.Lock()
+	r, _ := p.cursor()
+	if retfile, err = p.seekEntry(r + n); err == nil {
+		p.queueHold = true // play it next, in any mode
+		p.resume = nil
+	} else {
+		err = &PlayerError{Op: "seek", Err: err}
+	}
+	p.queueMu.Unlock()
+	p.enablePlay.SetThenSendBroadcast(true)
+	return
 }
 
 func (p *Player) PlayNextVideo() (retfile string, ok bool) {
@@ -129,20 +214,73 @@ func (p *Player) PlayPrevVideo() (retfile string, ok bool) {
 }
 
 func (p *Player) GetPlaying() (retfile string, ok bool) {
-	if p.Length() != 0 && p.IsRunning() {
-		retfile, err := p.Current()
-		return retfile, err == nil
-	} else {
+	if !p.IsRunning() {
 		return
 	}
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	list := p.GetPlaylist()
+	if r, n := p.cursor(); r < len(list) && n != 0 {
+		return list[r], true
+	}
+	return
 }
 
+// AddVideoToPlaylist inserts finename at index, from 0 to the playlist
+// length, and reports whether it was inserted.
 func (p *Player) AddVideoToPlaylist(finename string, index int) bool {
-	return nil == p.Insert(index, finename)
+	return nil == p.insertEntry(finename, index)
 }
 
+// insertEntry inserts path at index, from 0 to the playlist length. The
+// cursor stays on the current entry.
+func (p *Player) insertEntry(path string, index int) error {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	list, _ := p.Copy()
+	if index < 0 || index > len(list) {
+		return &PlayerError{Op: "playlist", Path: path, Err: goring.ErrOverflow}
+	}
+	r, n := p.cursor()
+	p.setPlaylist(slices.Insert(list, index, path))
+	if n > 0 {
+		if index <= r {
+			r++
+		}
+		p.seekEntry(r)
+	}
+	return nil
+}
+
+// RemoveVideoFromPlaylist removes the entry at index and reports whether it
+// was removed.
 func (p *Player) RemoveVideoFromPlaylist(index int) bool {
-	return nil == p.Remove(index)
+	return nil == p.removeEntry(index)
+}
+
+// removeEntry removes the entry at index. The cursor stays on the current
+// entry; if that is removed, the entry after it is played next.
+func (p *Player) removeEntry(index int) error {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	list, _ := p.Copy()
+	if index < 0 || index >= len(list) {
+		return &PlayerError{Op: "playlist", Err: goring.ErrOverflow}
+	}
+	r, _ := p.cursor()
+	p.setPlaylist(slices.Delete(list, index, index+1))
+	if len(list) > 1 {
+		switch {
+		case index < r:
+			r--
+		case index == r && (p.queueHold || r == 0):
+			p.queueHold = true // the entry after it is at index now
+		case index == r:
+			r--
+		}
+		p.seekEntry(r)
+	}
+	return nil
 }
 
 func (p *Player) GetPlaylistWithoutPath() []string {
@@ -163,7 +301,7 @@ func (p *Player) VideosDoesNotExist() (retstrs []string) {
 	retstrs, _ = p.Copy()
 	names := make([]string, 0)
 	for _, v := range retstrs {
-		if !gosystem.PathIsExist(v) {
+		if !p.isStreamURL(v) && !gosystem.PathIsExist(playLocation(v)) {
 			names = append(names, v)
 			// filepath.Base(v)
 		}
@@ -173,6 +311,7 @@ func (p *Player) VideosDoesNotExist() (retstrs []string) {
 
 func (p *Player) Stop() {
 	p.enablePlay.Set(false)
+	p.loopGen.Add(1)
 	p.condStop.SetThenSendBroadcast(true) //stop if it is playing
 }
 
@@ -193,55 +332,126 @@ func (p *Player) PlayIsActive() bool {
 }
 
 func (p *Player) GetSavedVolume() float64 {
+	p.volumeMu.Lock()
+	defer p.volumeMu.Unlock()
 	return p.currentVolume
 }
 
+// UpdateNewEventLinkedList replaces the playlist with list, as the embedded
+// EventLinkedList does, and wakes the player if it is waiting for entries.
+func (p *Player) UpdateNewEventLinkedList(list []string) (changed bool) {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	return p.setPlaylist(list)
+}
+
+// setPlaylist is UpdateNewEventLinkedList with queueMu held by the caller.
+func (p *Player) setPlaylist(list []string) (changed bool) {
+	if changed = p.EventLinkedList.UpdateNewEventLinkedList(list); changed {
+		p.pos = 0 // where the embedded list puts its cursor
+	}
+	p.condPlaylist.SetThenSendBroadcast(struct{}{})
+	return
+}
+
+// waitEntries waits until the playlist has entries. It fails only if the
+// player is closed.
+func (p *Player) waitEntries() error {
+	p.condPlaylist.Lock()
+	defer p.condPlaylist.Unlock()
+	// the playlist is changed before the broadcast takes the lock, so it
+	// cannot be missed between the check and Wait
+	for p.Length() == 0 && p.ctx.Err() == nil {
+		p.condPlaylist.Wait()
+	}
+	return p.ctx.Err()
+}
+
+// ConfigureNewPlaylist replaces the playlist with list. Settings recorded by
+// AddItem or ConfigureNewPlaylistItems are kept for the paths still listed.
 func (p *Player) ConfigureNewPlaylist(list []string) (chaged bool) {
-	chaged = p.UpdateNewEventLinkedList(list)
-	if chaged && p.IsRunning() { // reset play new playlist if playing
+	p.pruneItems(list)
+	return p.configureNewPlaylist(list, true, nil)
+}
+
+// configureNewPlaylist replaces the playlist with list, to be played from
+// its start. If interrupt is false the playing clip is not stopped, and the
+// new playlist starts when it ends. If place is not nil it is called with
+// queueMu held, the old playlist and its current entry, to move the cursor
+// elsewhere.
+func (p *Player) configureNewPlaylist(list []string, interrupt bool, place func(old []string, current string)) (chaged bool) {
+	p.queueMu.Lock()
+	var old []string
+	var current string
+	if place != nil {
+		old = p.GetPlaylist()
+		if r, n := p.cursor(); r < len(old) && n != 0 {
+			current = old[r]
+		}
+	}
+	chaged = p.setPlaylist(list)
+	if chaged {
+		p.loopGen.Add(1)
+		p.restartPlaylist()
+		p.resume = nil
+		if place != nil {
+			place(old, current)
+		}
+	}
+	p.queueMu.Unlock()
+	if chaged && interrupt && p.IsRunning() { // reset play new playlist if playing
 		p.condStop.SetThenSendBroadcast(true)
 	}
 	return
 }
 
 func (p *Player) ActiveViewDefaultPictures(picspath string) {
-	if p.startedViewPicture {
+	if p.startedViewPicture || p.ctx.Err() != nil {
 		return
 	}
 	if sutils.PathIsExist(picspath) {
-
+		p.startedViewPicture = true
+		p.wg.Add(1)
 		go func() {
 			var cmd *exec.Cmd
 			var err error
-			p.startedViewPicture = true
+			defer func() {
+				p.startedViewPicture = false
+				p.wg.Done()
+			}()
 			for {
 				p.condStartViewPicture.TestThenWaitSignalIfNotMatch(true)
 				p.condStartViewPicture.Set(false)
+				if p.ctx.Err() != nil {
+					return
+				}
 
 				if sutils.PathIsDir(picspath) {
-					cmd = exec.Command("omxiv", "-t", "3", "-a", "center", "--transition", "blend", "--duration", "3000", picspath)
+					cmd = exec.Command(p.config.OmxivBinary, p.omxivArgs(picspath, true)...)
 				} else if sutils.PathIsFile(picspath) {
-					cmd = exec.Command("omxiv", "-a", "center", "--transition", "blend", "--duration", "3000", picspath)
+					cmd = exec.Command(p.config.OmxivBinary, p.omxivArgs(picspath, false)...)
 				} else {
-					p.startedViewPicture = false
 					return
 				}
 				cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+				cmd.Env = p.processEnv()
 				cmd.Stdout = nil
 				cmd.Stderr = nil
 
 				if err = cmd.Start(); err != nil {
-					time.Sleep(time.Second * 1)
+					select {
+					case <-time.After(time.Second * 1):
+					case <-p.ctx.Done():
+						return
+					}
 					continue
 				}
 
 				p.condStopViewPicture.TestThenWaitSignalIfNotMatch(true)
 				p.condStopViewPicture.Set(false)
 				if cmd.ProcessState == nil && cmd.Process != nil { //still runnning
-					// syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
-					cmd.Process.Signal(syscall.SIGKILL)
+					syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) // kill the whole process group
 					// slogrus.Println("Release view picture")
-					// cmd.Process.Kill()
 				}
 
 				cmd.Wait()
@@ -253,130 +463,146 @@ func (p *Player) ActiveViewDefaultPictures(picspath string) {
 func (p *Player) __queueService() {
 	var filePlay FilePlay
 	var nextFile string
+	var err error
+	defer p.wg.Done()
 	// time.Sleep(time.Millisecond*100)
 	p.condStartViewPicture.SetThenSendSignal(true)
-	p.NextWait()
-	nextFile, _ = p.PrevWait()
-	filePlay.pathFile = nextFile
+	if p.waitEntries() != nil { // wait for the first entries
+		return
+	}
+	p.queueMu.Lock()
+	p.restartPlaylist()
+	p.queueMu.Unlock()
+	if nextFile, err = p.takeNext(); err != nil {
+		return
+	}
+	filePlay = p.filePlay(nextFile)
 	p.enablePlay.TestThenWaitSignalIfNotMatch(true)
 	for {
-		p.playingFile <- filePlay
+		gen := p.loopGen.Load()
+		select {
+		case p.playingFile <- filePlay:
+		case <-p.ctx.Done():
+			return
+		}
 		p.condFinishCurrentPlaying.WaitSignal()
 		// slogrus.Print("Waitting new file for play")
 		p.enablePlay.TestThenWaitSignalIfNotMatch(true)
-		nextFile, _ = p.SeekWait(p.SeekStep.Get())
-		filePlay.pathFile = nextFile
+		if p.ctx.Err() != nil {
+			return
+		}
+		if next, ok := p.takeInterrupt(); ok {
+			filePlay = next
+			continue
+		}
+		if filePlay.loops > 0 && p.loopGen.Load() == gen {
+			filePlay.loops--
+			continue
+		}
+		if nextFile, err = p.takeNext(); err != nil {
+			return
+		}
+		filePlay = p.filePlay(nextFile)
 	}
 }
 
 func (p *Player) __startService() {
 	var filePlay FilePlay
-	var args []string
-	var err error
-	var conn *dbus.Conn
+	var retry bool  // restart the failed stream instead of taking the next entry
+	var retries int // restarts of the current stream
+	var gen uint64  // loopGen when the current entry was taken
+	defer p.wg.Done()
 	slogrus.Print("Waiting for play")
+	p.wg.Add(1)
 	go p.__queueService()
 	for {
-		p.condFinishCurrentPlaying.Broadcast()
-		filePlay = <-p.playingFile
+		if !retry {
+			p.condFinishCurrentPlaying.Broadcast()
+			select {
+			case filePlay = <-p.playingFile:
+			case <-p.ctx.Done():
+				return
+			}
+			retries, gen = 0, p.loopGen.Load()
+		}
+		retry = false
 		slogrus.Print("New file for play: ", filePlay.pathFile)
-		if !filePlay.isStreamLink && !sutils.PathIsFile(filePlay.pathFile) {
-			time.Sleep(time.Millisecond * 500)
+		if !filePlay.isStreamLink && !sutils.PathIsFile(filePlay.location) {
+			p.emit(PlayerEvent{Type: EventSkipped, Path: filePlay.pathFile, Err: &PlayerError{Op: "play", Path: filePlay.pathFile, Err: ErrFileMissing}})
+			p.sleep(p.config.MissingFileDelay)
 			continue
 		}
-		if len(p.argsOmx) != 0 {
-			args = p.argsOmx
-		} else {
-			args = make([]string, 0)
-		}
-
-		if len(filePlay.args) != 0 {
-			args = append(args, filePlay.args...)
-		}
-		args = append(args, filePlay.pathFile)
-
-		p.command = exec.Command(exeOxmPlayer, args...)
-		p.CommandKeysBuffer.Reset()
-		p.command.Stdin = p.CommandKeysBuffer
-		p.command.Stdout = nil
-		p.command.Stderr = nil
-		//	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} //for linux only
-		p.command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-		err = p.command.Start()
-		if err != nil {
-			slogrus.Printf("Can not start: %s - %s\n", filePlay.pathFile, err.Error())
+		if p.isQuarantined(filePlay.pathFile) {
+			p.emit(PlayerEvent{Type: EventSkipped, Path: filePlay.pathFile, Err: &PlayerError{Op: "play", Path: filePlay.pathFile, Err: ErrQuarantined}})
+			p.sleep(p.config.MissingFileDelay)
 			continue
 		}
-		p.condStopViewPicture.SetThenSendSignal(true)
-		killcmd := func() {
-			// if p.command.ProcessState == nil && p.command.Process != nil {
-			if p.command.Process != nil {
-				// p.CmdQuit()
-				p.CommandKeysBuffer.WriteString(keyQuit)
-				syscall.Kill(-p.command.Process.Pid, syscall.SIGKILL)
-				// p.command.Process.Signal(syscall.SIGKILL) //force kill process
-			}
-		}
-		func() { // release dbus connection if exits
-			endFunc := false
-			defer func() {
-				if !endFunc {
-					killcmd()
-					p.command.Wait()
-				} //force kill process
-				p.condStartViewPicture.SetThenSendSignal(true)
-				p.condStop.SetThenSendBroadcast(false)
-				// p.condStop.Set(false) //clear signal send by controler
-				p.condStart.Set(false)
-			}()
-
-			err = setupDbusEnvironment() //wait timeout dbus then set enroviment dbus
-			if err != nil {
-				slogrus.Print("can not setupDbusEnvironment")
-				return
-			}
-
-			conn, err = getDbusConnection()
-			if err != nil {
-				slogrus.Print("can not get setupDbusEnvironment")
-				return
-			}
-			p.bus = conn.Object(ifaceOmx, pathMpris)
-
-			ctx, cancleFunc := context.WithCancel(context.Background())
-			go func(ctx context.Context) {
-				select {
-				case <-p.condStop.TestThenWaitSignalIfMatch(false, true): //force kill
-					killcmd()
-				case <-ctx.Done():
-					p.condStop.Signal()
-				}
-				if conn != nil {
-					slogrus.Print("Release dbus connection")
-					conn.Close()
-				}
-			}(ctx)
-			go func() {
-				p.WaitForReadyWithTimeOut(time.Second * 10)
-				if _, err = p.CmdVolume(p.GetSavedVolume()); err != nil {
-					slogrus.Error("Can not Set Volume", err)
-				}
-				// continue
-			}()
-			p.condStart.SetThenSendBroadcast(true)
-			err = p.command.Wait() // wait for end video
-			cancleFunc()
-			endFunc = true
-			slogrus.Print("Finish play ", filePlay.pathFile)
-		}()
+		stopped, err := p.playSession(p.getBackend(), filePlay)
+		retry = p.settle(filePlay, stopped, err, &retries, gen)
 	}
 }
 
 func (p *Player) Quit() {
-	if b, err := p.CmdCanQuit(); err == nil && b {
-		p.Quit()
+	if b, err := p.CmdCanQuit(); (err == nil && b) || errors.Is(err, ErrNotSupported) {
+		p.CmdQuit()
 	} else {
-		p.CommandKeysBuffer.WriteString(keyQuit)
+		p.sendKey(keyQuit)
+	}
+}
+
+// sendKey writes key to the standard input of the omxplayer process that is
+// playing, as if it was typed in its terminal.
+func (p *Player) sendKey(key string) error {
+	s, ok := p.currentSession().(*omxSession)
+	if !ok {
+		return &PlayerError{Op: "key", Err: ErrNotRunning}
+	}
+	_, err := io.WriteString(s.keys, key)
+	return err
+}
+
+// Close stops playback, kills the omxplayer and omxiv process groups, closes
+// the D-Bus connection and waits for every goroutine started by the player to
+// exit. If ctx is done before cleanup finishes, an error wrapping ctx.Err() is
+// returned. The player must not be used after Close; create a new one instead.
+func (p *Player) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		playersMu.Lock()
+		delete(players, p.dbusName)
+		if Gplayer == p {
+			Gplayer = nil
+		}
+		playersMu.Unlock()
+		p.Stop()
+		p.SetGapless(false, 0, 0) // kills a preloaded process
+		p.CancelFunc()
+	})
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		// events emitted from now on are not recorded, so recording cannot
+		// be added to while it is waited for
+		p.closeSubscribers()
+		p.recording.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		// wake-ups can race with goroutines about to wait, so repeat them
+		p.condStop.SetThenSendBroadcast(true)
+		p.enablePlay.SendBroacast()
+		p.condFinishCurrentPlaying.SendBroacast()
+		p.condPlaylist.SetThenSendBroadcast(struct{}{})
+		p.condStartViewPicture.SetThenSendBroadcast(true)
+		p.condStopViewPicture.SetThenSendBroadcast(true)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("close player %s: %w", p.dbusName, ctx.Err())
+		case <-ticker.C:
+		}
 	}
 }
 
@@ -394,190 +620,362 @@ func (p *Player) WaitFinishCurrentPlaying() {
 	}
 }
 
+// WaitFinishCurrentPlayingContext waits until the file that is playing ends,
+// or ctx is done.
+func (p *Player) WaitFinishCurrentPlayingContext(ctx context.Context) error {
+	events := p.Events()
+	defer p.Unsubscribe(events)
+	if !p.condStart.Get() {
+		return nil
+	}
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok || ev.Type == EventFinished || ev.Type == EventFailed {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 // IsReady checks to see if the Player instance is ready to accept D-Bus
 // commands. If the player is ready and can accept commands, the function
-// returns true, otherwise it returns false.
+// returns true, otherwise it returns false. Readiness follows omxplayer's
+// ownership of its D-Bus name, so this does not make a D-Bus call.
 func (p *Player) IsReady() bool {
-	result, err := p.CmdCanQuit()
-	if err == nil && result {
-		return true
-	} else {
-		return false
-	}
+	return p.ready.get() && p.currentSession() != nil
 }
 
 // WaitForReady waits until the Player instance is ready to accept D-Bus
 // commands and then returns.
 func (p *Player) WaitForReady() {
-	for !p.IsReady() {
-		time.Sleep(50 * time.Millisecond)
-	}
+	p.WaitForReadyContext(context.Background())
 }
 
 func (p *Player) WaitForReadyWithTimeOut(timeout time.Duration) bool {
-	timeoutAt := time.Now().Add(timeout)
-	for !p.IsReady() {
-		if time.Now().After(timeoutAt) {
-			return false
-		}
-		time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return p.WaitForReadyContext(ctx) == nil
+}
+
+// WaitForReadyContext waits until the Player instance is ready to accept
+// D-Bus commands, or ctx is done.
+func (p *Player) WaitForReadyContext(ctx context.Context) error {
+	select {
+	case <-p.ready.wait(true):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return true
 }
 
 func (p *Player) WaitForQuitTimeOut(timeout time.Duration) bool {
-	timeoutAt := time.Now().Add(timeout)
-	for p.IsReady() {
-		if time.Now().Unix() > timeoutAt.Unix() {
-			return false
-		}
-		time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return p.WaitForQuitContext(ctx) == nil
+}
+
+// WaitForQuitContext waits until the omxplayer process stops accepting D-Bus
+// commands, or ctx is done.
+func (p *Player) WaitForQuitContext(ctx context.Context) error {
+	select {
+	case <-p.ready.wait(false):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return true
 }
 
 // Quit stops the currently playing video and terminates the omxplayer process.
 // See https://github.com/popcornmix/omxplayer#quit for more details.
 func (p *Player) CmdQuit() error {
-	return sutils.DbusCall(p.bus, cmdQuit)
+	return p.CmdQuitContext(context.Background())
+}
+
+// CmdQuitContext is CmdQuit honoring ctx.
+func (p *Player) CmdQuitContext(ctx context.Context) (err error) {
+	defer p.closedError(&err)
+	s, err := p.control(cmdQuit)
+	if err != nil {
+		return err
+	}
+	return s.Quit(ctx)
 }
 
 // CanQuit returns true if the player can quit, false otherwise. See
 // https://github.com/popcornmix/omxplayer#canquit for more details.
 func (p *Player) CmdCanQuit() (bool, error) {
-	return sutils.DbusGetBool(p.bus, propCanQuit)
+	return p.CmdCanQuitContext(context.Background())
+}
+
+// CmdCanQuitContext is CmdCanQuit honoring ctx.
+func (p *Player) CmdCanQuitContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, propCanQuit)
 }
 
 // Fullscreen returns true if the player is fullscreen, false otherwise. See
 // https://github.com/popcornmix/omxplayer#fullscreen for more details.
 func (p *Player) CmdFullscreen() (bool, error) {
-	return sutils.DbusGetBool(p.bus, propFullscreen)
+	return p.CmdFullscreenContext(context.Background())
+}
+
+// CmdFullscreenContext is CmdFullscreen honoring ctx.
+func (p *Player) CmdFullscreenContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, propFullscreen)
 }
 
 // CanSetFullscreen returns true if the player can be set to fullscreen, false
 // otherwise. See https://github.com/popcornmix/omxplayer#cansetfullscreen for
 // more details.
 func (p *Player) CmdCanSetFullscreen() (bool, error) {
-	return sutils.DbusGetBool(p.bus, propCanSetFullscreen)
+	return p.CmdCanSetFullscreenContext(context.Background())
+}
+
+// CmdCanSetFullscreenContext is CmdCanSetFullscreen honoring ctx.
+func (p *Player) CmdCanSetFullscreenContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, propCanSetFullscreen)
 }
 
 // CanRaise returns true if the player can be brought to the front, false
 // otherwise. See https://github.com/popcornmix/omxplayer#canraise for more
 // details.
 func (p *Player) CmdCanRaise() (bool, error) {
-	return sutils.DbusGetBool(p.bus, propCanRaise)
+	return p.CmdCanRaiseContext(context.Background())
+}
+
+// CmdCanRaiseContext is CmdCanRaise honoring ctx.
+func (p *Player) CmdCanRaiseContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, propCanRaise)
 }
 
 // HasTrackList returns true if the player has a track list, false otherwise.
 // See https://github.com/popcornmix/omxplayer#hastracklist for more details.
 func (p *Player) CmdHasTrackList() (bool, error) {
-	return sutils.DbusGetBool(p.bus, propHasTrackList)
+	return p.CmdHasTrackListContext(context.Background())
+}
+
+// CmdHasTrackListContext is CmdHasTrackList honoring ctx.
+func (p *Player) CmdHasTrackListContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, propHasTrackList)
 }
 
 // Identity returns the name of the player instance. See
 // https://github.com/popcornmix/omxplayer#identity for more details.
 func (p *Player) CmdIdentity() (string, error) {
-	return sutils.DbusGetString(p.bus, propIdentity)
+	return p.CmdIdentityContext(context.Background())
+}
+
+// CmdIdentityContext is CmdIdentity honoring ctx.
+func (p *Player) CmdIdentityContext(ctx context.Context) (string, error) {
+	return dbusCallValueContext[string](ctx, p, propIdentity)
 }
 
 // SupportedURISchemes returns a list of playable URI formats. See
 // https://github.com/popcornmix/omxplayer#supportedurischemes for more details.
 func (p *Player) CmdSupportedURISchemes() ([]string, error) {
-	return sutils.DbusGetStringArray(p.bus, propSupportedURISchemes)
+	return p.CmdSupportedURISchemesContext(context.Background())
+}
+
+// CmdSupportedURISchemesContext is CmdSupportedURISchemes honoring ctx.
+func (p *Player) CmdSupportedURISchemesContext(ctx context.Context) ([]string, error) {
+	return dbusCallValueContext[[]string](ctx, p, propSupportedURISchemes)
 }
 
 // SupportedMimeTypes returns a list of supported MIME types. See
 // https://github.com/popcornmix/omxplayer#supportedmimetypes for more details.
 func (p *Player) CmdSupportedMimeTypes() ([]string, error) {
-	return sutils.DbusGetStringArray(p.bus, propSupportedMimeTypes)
+	return p.CmdSupportedMimeTypesContext(context.Background())
+}
+
+// CmdSupportedMimeTypesContext is CmdSupportedMimeTypes honoring ctx.
+func (p *Player) CmdSupportedMimeTypesContext(ctx context.Context) ([]string, error) {
+	return dbusCallValueContext[[]string](ctx, p, propSupportedMimeTypes)
 }
 
 // CanGoNext returns true if the player can skip to the next track, false
 // otherwise. See https://github.com/popcornmix/omxplayer#cangonext for more
 // details.
 func (p *Player) CmdCanGoNext() (bool, error) {
-	return sutils.DbusGetBool(p.bus, propCanGoNext)
+	return p.CmdCanGoNextContext(context.Background())
+}
+
+// CmdCanGoNextContext is CmdCanGoNext honoring ctx.
+func (p *Player) CmdCanGoNextContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, propCanGoNext)
 }
 
 // CanGoPrevious returns true if the player can skip to previous track, false
 // otherwise. See https://github.com/popcornmix/omxplayer#cangoprevious for more
 // details.
 func (p *Player) CmdCanGoPrevious() (bool, error) {
-	return sutils.DbusGetBool(p.bus, propCanGoPrevious)
+	return p.CmdCanGoPreviousContext(context.Background())
+}
+
+// CmdCanGoPreviousContext is CmdCanGoPrevious honoring ctx.
+func (p *Player) CmdCanGoPreviousContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, propCanGoPrevious)
 }
 
 // CanSeek returns true if the player can seek, false otherwise. See
 // https://github.com/popcornmix/omxplayer#canseek for more details.
 func (p *Player) CmdCanSeek() (bool, error) {
-	return sutils.DbusGetBool(p.bus, cmdSeek)
+	return p.CmdCanSeekContext(context.Background())
+}
+
+// CmdCanSeekContext is CmdCanSeek honoring ctx.
+func (p *Player) CmdCanSeekContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, propCanSeek)
 }
 
 // CanControl returns true if the player can be controlled, false otherwise. See
 // https://github.com/popcornmix/omxplayer#cancontrol for more details.
 func (p *Player) CmdCanControl() (bool, error) {
-	return sutils.DbusGetBool(p.bus, propCanControl)
+	return p.CmdCanControlContext(context.Background())
+}
+
+// CmdCanControlContext is CmdCanControl honoring ctx.
+func (p *Player) CmdCanControlContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, propCanControl)
 }
 
 // CanPlay returns true if the player can play, false otherwise. See
 // https://github.com/popcornmix/omxplayer#canplay for more details.
 func (p *Player) CmdCanPlay() (bool, error) {
-	return sutils.DbusGetBool(p.bus, propCanPlay)
+	return p.CmdCanPlayContext(context.Background())
+}
+
+// CmdCanPlayContext is CmdCanPlay honoring ctx.
+func (p *Player) CmdCanPlayContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, propCanPlay)
 }
 
 // CanPause returns true if the player can pause, false otherwise. See
 // https://github.com/popcornmix/omxplayer#canpause for more details.
 func (p *Player) CmdCanPause() (bool, error) {
-	return sutils.DbusGetBool(p.bus, propCanPause)
+	return p.CmdCanPauseContext(context.Background())
+}
+
+// CmdCanPauseContext is CmdCanPause honoring ctx.
+func (p *Player) CmdCanPauseContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, propCanPause)
 }
 
 // Next tells the player to skip to the next chapter. See
 // https://github.com/popcornmix/omxplayer#next for more details.
 func (p *Player) CmdNextTrack() error {
-	return sutils.DbusCall(p.bus, cmdNext)
+	return p.CmdNextTrackContext(context.Background())
+}
+
+// CmdNextTrackContext is CmdNextTrack honoring ctx.
+func (p *Player) CmdNextTrackContext(ctx context.Context) error {
+	return p.dbusCallContext(ctx, cmdNext)
 }
 
 // Previous tells the player to skip to the previous chapter. See
 // https://github.com/popcornmix/omxplayer#previous for more details.
 func (p *Player) CmdPreviousTrack() error {
-	return sutils.DbusCall(p.bus, cmdPrevious)
+	return p.CmdPreviousTrackContext(context.Background())
+}
+
+// CmdPreviousTrackContext is CmdPreviousTrack honoring ctx.
+func (p *Player) CmdPreviousTrackContext(ctx context.Context) error {
+	return p.dbusCallContext(ctx, cmdPrevious)
 }
 
 // Pause pauses the player if it is playing. Otherwise, it resumes playback. See
 // https://github.com/popcornmix/omxplayer#pause for more details.
 func (p *Player) CmdPause() error {
-	p.command.Stdin = strings.NewReader(keyPause)
-	return sutils.DbusCall(p.bus, cmdPause)
+	return p.CmdPauseContext(context.Background())
+}
+
+// CmdPauseContext is CmdPause honoring ctx.
+func (p *Player) CmdPauseContext(ctx context.Context) (err error) {
+	defer p.closedError(&err)
+	s, err := p.control(cmdPause)
+	if err != nil {
+		return err
+	}
+	if err = s.Pause(ctx); err != nil {
+		return err
+	}
+	p.emitPlaybackStatus()
+	return nil
 }
 
 // Play play the video. If the video is playing, it has no effect,
 // if it is paused it will play from current position.
 // See https://github.com/popcornmix/omxplayer#play for more details.
 func (p *Player) CmdPlay() error {
-	return sutils.DbusCall(p.bus, cmdPlay)
+	return p.CmdPlayContext(context.Background())
+}
+
+// CmdPlayContext is CmdPlay honoring ctx.
+func (p *Player) CmdPlayContext(ctx context.Context) (err error) {
+	defer p.closedError(&err)
+	s, err := p.control(cmdPlay)
+	if err != nil {
+		return err
+	}
+	if err = s.Play(ctx); err != nil {
+		return err
+	}
+	p.emitPlaybackStatus()
+	return nil
 }
 
 // PlayPause pauses the player if it is playing. Otherwise, it resumes playback.
 // See https://github.com/popcornmix/omxplayer#playpause for more details.
 func (p *Player) CmdPlayPause() error {
-	return sutils.DbusCall(p.bus, cmdPlayPause)
+	return p.CmdPlayPauseContext(context.Background())
+}
+
+// CmdPlayPauseContext is CmdPlayPause honoring ctx.
+func (p *Player) CmdPlayPauseContext(ctx context.Context) (err error) {
+	defer p.closedError(&err)
+	s, err := p.control(cmdPlayPause)
+	if err != nil {
+		return err
+	}
+	if err = s.PlayPause(ctx); err != nil {
+		return err
+	}
+	p.emitPlaybackStatus()
+	return nil
 }
 
 // Stop tells the player to stop playing the video. See
 // https://github.com/popcornmix/omxplayer#stop for more details.
 func (p *Player) CmdStop() bool {
-	if p.IsRunning() || p.IsReady() {
-		sutils.DbusCall(p.bus, cmdStop)
-		if p.command.ProcessState == nil && p.command.Process != nil {
-			syscall.Kill(-p.command.Process.Pid, syscall.SIGKILL)
-			p.command.Process.Kill()
-		}
-		p.command.Wait()
-		return true
-		// return !p.IsRunning()
-	}
+	p.CmdStopContext(context.Background())
 	return true
 }
 
+// CmdStopContext is CmdStop honoring ctx. It fails if ctx is done before the
+// clip has exited.
+func (p *Player) CmdStopContext(ctx context.Context) error {
+	s := p.currentSession()
+	if s == nil {
+		return nil
+	}
+	if _, ok := s.(*omxSession); ok {
+		p.dbusCallContext(ctx, cmdStop)
+	}
+	s.Kill()
+	exited := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+		return nil
+	case <-ctx.Done():
+		return &PlayerError{Op: cmdStop, Err: ctx.Err()}
+	}
+}
+
 // Seek performs a relative seek from the current video position. See
 // https://github.com/popcornmix/omxplayer#seek for more details.
 func (p *Player) CmdSeek(amount int64) (int64, error) {
@@ -585,11 +983,17 @@ func (p *Player) CmdSeek(amount int64) (int64, error) {
 	//		"path":        cmdSeek,
 	//		"paramAmount": amount,
 	//	}).Debug("omxplayer: dbus call")
-	call := p.bus.Call(cmdSeek, 0, amount)
-	if call.Err != nil {
-		return 0, call.Err
+	return p.CmdSeekContext(context.Background(), amount)
+}
+
+// CmdSeekContext is CmdSeek honoring ctx.
+func (p *Player) CmdSeekContext(ctx context.Context, amount int64) (_ int64, err error) {
+	defer p.closedError(&err)
+	s, err := p.control(cmdSeek)
+	if err != nil {
+		return 0, err
 	}
-	return call.Body[0].(int64), nil
+	return s.Seek(ctx, amount)
 }
 
 // SetPosition performs an absolute seek to the specified video position. See
@@ -600,11 +1004,17 @@ func (p *Player) CmdSetPosition(path string, position int64) (int64, error) {
 	//		"paramPath":     path,
 	//		"paramPosition": position,
 	//	}).Debug("omxplayer: dbus call")
-	call := p.bus.Call(cmdSetPosition, 0, dbus.ObjectPath(path), position)
-	if call.Err != nil {
-		return 0, call.Err
+	return p.CmdSetPositionContext(context.Background(), path, position)
+}
+
+// CmdSetPositionContext is CmdSetPosition honoring ctx.
+func (p *Player) CmdSetPositionContext(ctx context.Context, path string, position int64) (_ int64, err error) {
+	defer p.closedError(&err)
+	s, err := p.control(cmdSetPosition)
+	if err != nil {
+		return 0, err
 	}
-	return call.Body[0].(int64), nil
+	return s.SetPosition(ctx, path, position)
 }
 
 // PlaybackStatus returns the current state of the player. See
@@ -612,23 +1022,55 @@ func (p *Player) CmdSetPosition(path string, position int64) (int64, error) {
 //The current state of the player, either "Paused" or "Playing".
 
 func (p *Player) CmdPlaybackStatus() (string, error) {
-	return sutils.DbusGetString(p.bus, propPlaybackStatus)
+	return p.CmdPlaybackStatusContext(context.Background())
+}
+
+// CmdPlaybackStatusContext is CmdPlaybackStatus honoring ctx.
+func (p *Player) CmdPlaybackStatusContext(ctx context.Context) (_ string, err error) {
+	defer p.closedError(&err)
+	s, err := p.control(propPlaybackStatus)
+	if err != nil {
+		return "", err
+	}
+	return s.PlaybackStatus(ctx)
 }
 
 func (p *Player) CmdGetSource() (string, error) {
-	return sutils.DbusGetString(p.bus, cmdGetSource)
+	return p.CmdGetSourceContext(context.Background())
+}
+
+// CmdGetSourceContext is CmdGetSource honoring ctx.
+func (p *Player) CmdGetSourceContext(ctx context.Context) (string, error) {
+	return dbusCallValueContext[string](ctx, p, cmdGetSource)
 }
 
 func (p *Player) CmdOpenUri(uripath string) error {
-	call := p.bus.Call(cmdOpenUri, 0, uripath)
-	if call.Err != nil {
-		return call.Err
-	}
-	return nil
+	return p.CmdOpenUriContext(context.Background(), uripath)
+}
+
+// CmdOpenUriContext is CmdOpenUri honoring ctx.
+func (p *Player) CmdOpenUriContext(ctx context.Context, uripath string) error {
+	return p.dbusCallContext(ctx, cmdOpenUri, uripath)
 }
 
 func (p *Player) CmdRaise() (bool, error) {
-	return sutils.DbusGetBool(p.bus, cmdRaise)
+	return p.CmdRaiseContext(context.Background())
+}
+
+// CmdRaiseContext is CmdRaise honoring ctx.
+func (p *Player) CmdRaiseContext(ctx context.Context) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, cmdRaise)
+}
+
+// CmdSetLayer moves the video to display layer layer. See
+// https://github.com/popcornmix/omxplayer#setlayer for more details.
+func (p *Player) CmdSetLayer(layer int64) error {
+	return p.CmdSetLayerContext(context.Background(), layer)
+}
+
+// CmdSetLayerContext is CmdSetLayer honoring ctx.
+func (p *Player) CmdSetLayerContext(ctx context.Context, layer int64) error {
+	return p.dbusCallContext(ctx, cmdSetLayer, layer)
 }
 
 // Volume returns the current volume. Sets a new volume when an argument is
@@ -639,15 +1081,32 @@ func (p *Player) CmdVolume(volume ...float64) (float64, error) {
 	//		"path":        cmdVolume,
 	//		"paramVolume": volume,
 	//	}).Debug("omxplayer: dbus call")
+	return p.CmdVolumeContext(context.Background(), volume...)
+}
+
+// CmdVolumeContext is CmdVolume honoring ctx.
+func (p *Player) CmdVolumeContext(ctx context.Context, volume ...float64) (_ float64, err error) {
+	defer p.closedError(&err)
+	s, err := p.control(cmdVolume)
+	if err != nil {
+		return 0, err
+	}
 	if len(volume) == 0 {
-		return sutils.DbusGetFloat64(p.bus, cmdVolume)
+		return s.Volume(ctx)
 	}
-	call := p.bus.Call(cmdVolume, 0, volume[0])
-	if call.Err != nil {
-		return 0, call.Err
+	v, err := s.Volume(ctx, volume[0])
+	if err != nil {
+		return 0, err
 	}
-	p.currentVolume = call.Body[0].(float64)
-	return p.currentVolume, nil
+	p.volumeMu.Lock()
+	previous := p.currentVolume
+	p.currentVolume = v
+	p.volumeMu.Unlock()
+	if v != previous {
+		fp, started := p.nowPlaying()
+		p.emit(PlayerEvent{Type: EventVolumeChanged, Path: fp.pathFile, StartTime: started, Volume: v})
+	}
+	return v, nil
 }
 
 // Volume returns the current volume. Sets a new volume when an argument is
@@ -669,91 +1128,186 @@ func (p *Player) CmdVolumePercent(volume ...int) (volint int, err error) {
 // Mute mutes the video's audio stream. See
 // https://github.com/popcornmix/omxplayer#mute for more details.
 func (p *Player) CmdMute() error {
-	return sutils.DbusCall(p.bus, cmdMute)
+	return p.CmdMuteContext(context.Background())
+}
+
+// CmdMuteContext is CmdMute honoring ctx.
+func (p *Player) CmdMuteContext(ctx context.Context) error {
+	return p.dbusCallContext(ctx, cmdMute)
 }
 
 // Unmute unmutes the video's audio stream. See
 // https://github.com/popcornmix/omxplayer#unmute for more details.
 func (p *Player) CmdUnmute() error {
-	return sutils.DbusCall(p.bus, cmdUnmute)
+	return p.CmdUnmuteContext(context.Background())
+}
+
+// CmdUnmuteContext is CmdUnmute honoring ctx.
+func (p *Player) CmdUnmuteContext(ctx context.Context) error {
+	return p.dbusCallContext(ctx, cmdUnmute)
 }
 
 // Position returns the current position in the video in milliseconds. See
 // https://github.com/popcornmix/omxplayer#position for more details.
 func (p *Player) Position() (int64, error) {
-	return sutils.DbusGetInt64(p.bus, propPosition)
+	return p.PositionContext(context.Background())
+}
+
+// PositionContext is Position honoring ctx.
+func (p *Player) PositionContext(ctx context.Context) (_ int64, err error) {
+	defer p.closedError(&err)
+	s, err := p.control(propPosition)
+	if err != nil {
+		return 0, err
+	}
+	return s.Position(ctx)
 }
 
 // Aspect returns the aspect ratio. See
 // https://github.com/popcornmix/omxplayer/blob/master/OMXControl.cpp#L362.
 func (p *Player) CmdAspect() (float64, error) {
-	return sutils.DbusGetFloat64(p.bus, propAspect)
+	return p.CmdAspectContext(context.Background())
+}
+
+// CmdAspectContext is CmdAspect honoring ctx.
+func (p *Player) CmdAspectContext(ctx context.Context) (float64, error) {
+	return dbusCallValueContext[float64](ctx, p, propAspect)
 }
 
 // VideoStreamCount returns the number of available video streams. See
 // https://github.com/popcornmix/omxplayer/blob/master/OMXControl.cpp#L369.
 func (p *Player) CmdVideoStreamCount() (int64, error) {
-	return sutils.DbusGetInt64(p.bus, propVideoStreamCount)
+	return p.CmdVideoStreamCountContext(context.Background())
+}
+
+// CmdVideoStreamCountContext is CmdVideoStreamCount honoring ctx.
+func (p *Player) CmdVideoStreamCountContext(ctx context.Context) (int64, error) {
+	return dbusCallValueContext[int64](ctx, p, propVideoStreamCount)
 }
 
 // ResWidth returns the width of the video. See
 // https://github.com/popcornmix/omxplayer/blob/master/OMXControl.cpp#L376.
 func (p *Player) CmdResWidth() (int64, error) {
-	return sutils.DbusGetInt64(p.bus, propResWidth)
+	return p.CmdResWidthContext(context.Background())
+}
+
+// CmdResWidthContext is CmdResWidth honoring ctx.
+func (p *Player) CmdResWidthContext(ctx context.Context) (int64, error) {
+	return dbusCallValueContext[int64](ctx, p, propResWidth)
 }
 
 // ResHeight returns the height of the video. See
 // https://github.com/popcornmix/omxplayer/blob/master/OMXControl.cpp#L383.
 func (p *Player) ResHeight() (int64, error) {
-	return sutils.DbusGetInt64(p.bus, propResHeight)
+	return p.ResHeightContext(context.Background())
+}
+
+// ResHeightContext is ResHeight honoring ctx.
+func (p *Player) ResHeightContext(ctx context.Context) (int64, error) {
+	return dbusCallValueContext[int64](ctx, p, propResHeight)
 }
 
 // Duration returns the total length of the video in milliseconds. See
 // https://github.com/popcornmix/omxplayer#duration for more details.
 func (p *Player) CmdDuration() (int64, error) {
-	return sutils.DbusGetInt64(p.bus, propDuration)
+	return p.CmdDurationContext(context.Background())
+}
+
+// CmdDurationContext is CmdDuration honoring ctx.
+func (p *Player) CmdDurationContext(ctx context.Context) (_ int64, err error) {
+	defer p.closedError(&err)
+	s, err := p.control(propDuration)
+	if err != nil {
+		return 0, err
+	}
+	return s.Duration(ctx)
 }
 
 // MinimumRate returns the minimum playback rate. See
 // https://github.com/popcornmix/omxplayer#minimumrate for more details.
 func (p *Player) CmdMinimumRate() (float64, error) {
-	return sutils.DbusGetFloat64(p.bus, propMinimumRate)
+	return p.CmdMinimumRateContext(context.Background())
+}
+
+// CmdMinimumRateContext is CmdMinimumRate honoring ctx.
+func (p *Player) CmdMinimumRateContext(ctx context.Context) (float64, error) {
+	return dbusCallValueContext[float64](ctx, p, propMinimumRate)
 }
 
 // MaximumRate returns the maximum playback rate. See
 // https://github.com/popcornmix/omxplayer#maximumrate for more details.
 func (p *Player) CmdMaximumRate() (float64, error) {
-	return sutils.DbusGetFloat64(p.bus, propMaximumRate)
+	return p.CmdMaximumRateContext(context.Background())
+}
+
+// CmdMaximumRateContext is CmdMaximumRate honoring ctx.
+func (p *Player) CmdMaximumRateContext(ctx context.Context) (float64, error) {
+	return dbusCallValueContext[float64](ctx, p, propMaximumRate)
 }
 
 // ListSubtitles returns a list of the subtitles available in the video file.
 // See https://github.com/popcornmix/omxplayer#listsubtitles for more details.
 func (p *Player) ListSubtitles() ([]string, error) {
-	return sutils.DbusGetStringArray(p.bus, cmdListSubtitles)
+	return p.ListSubtitlesContext(context.Background())
+}
+
+// ListSubtitlesContext is ListSubtitles honoring ctx.
+func (p *Player) ListSubtitlesContext(ctx context.Context) (_ []string, err error) {
+	defer p.closedError(&err)
+	s, err := p.control(cmdListSubtitles)
+	if err != nil {
+		return nil, err
+	}
+	return s.ListSubtitles(ctx)
 }
 
 // HideVideo is an undocumented D-Bus method. See
 // https://github.com/popcornmix/omxplayer/blob/master/OMXControl.cpp#L457.
 func (p *Player) CmdHideVideo() error {
-	return sutils.DbusCall(p.bus, cmdHideVideo)
+	return p.CmdHideVideoContext(context.Background())
+}
+
+// CmdHideVideoContext is CmdHideVideo honoring ctx.
+func (p *Player) CmdHideVideoContext(ctx context.Context) error {
+	return p.dbusCallContext(ctx, cmdHideVideo)
 }
 
 // UnHideVideo is an undocumented D-Bus method. See
 // https://github.com/popcornmix/omxplayer/blob/master/OMXControl.cpp#L462.
 func (p *Player) CmdUnHideVideo() error {
-	return sutils.DbusCall(p.bus, cmdUnHideVideo)
+	return p.CmdUnHideVideoContext(context.Background())
+}
+
+// CmdUnHideVideoContext is CmdUnHideVideo honoring ctx.
+func (p *Player) CmdUnHideVideoContext(ctx context.Context) error {
+	return p.dbusCallContext(ctx, cmdUnHideVideo)
 }
 
 // ListAudio returns a list of the audio tracks available in the video file. See
 // https://github.com/popcornmix/omxplayer#listaudio for more details.
 func (p *Player) ListAudio() ([]string, error) {
-	return sutils.DbusGetStringArray(p.bus, cmdListAudio)
+	return p.ListAudioContext(context.Background())
+}
+
+// ListAudioContext is ListAudio honoring ctx.
+func (p *Player) ListAudioContext(ctx context.Context) (_ []string, err error) {
+	defer p.closedError(&err)
+	s, err := p.control(cmdListAudio)
+	if err != nil {
+		return nil, err
+	}
+	return s.ListAudio(ctx)
 }
 
 // ListVideo returns a list of the video tracks available in the video file. See
 // https://github.com/popcornmix/omxplayer#listvideo for more details.
 func (p *Player) CmdListVideo() ([]string, error) {
-	return sutils.DbusGetStringArray(p.bus, cmdListVideo)
+	return p.CmdListVideoContext(context.Background())
+}
+
+// CmdListVideoContext is CmdListVideo honoring ctx.
+func (p *Player) CmdListVideoContext(ctx context.Context) ([]string, error) {
+	return dbusCallValueContext[[]string](ctx, p, cmdListVideo)
 }
 
 // SelectSubtitle specifies which subtitle track should be used. See
@@ -763,11 +1317,12 @@ func (p *Player) CmdSelectSubtitle(index int32) (bool, error) {
 	//		"path":       cmdSelectSubtitle,
 	//		"paramIndex": index,
 	//	}).Debug("omxplayer: dbus call")
-	call := p.bus.Call(cmdSelectSubtitle, 0, index)
-	if call.Err != nil {
-		return false, call.Err
-	}
-	return call.Body[0].(bool), nil
+	return p.CmdSelectSubtitleContext(context.Background(), index)
+}
+
+// CmdSelectSubtitleContext is CmdSelectSubtitle honoring ctx.
+func (p *Player) CmdSelectSubtitleContext(ctx context.Context, index int32) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, cmdSelectSubtitle, index)
 }
 
 // SelectAudio specifies which audio track should be used. See
@@ -777,23 +1332,34 @@ func (p *Player) CmdSelectAudio(index int32) (bool, error) {
 	//		"path":       cmdSelectAudio,
 	//		"paramIndex": index,
 	//	}).Debug("omxplayer: dbus call")
-	call := p.bus.Call(cmdSelectAudio, 0, index)
-	if call.Err != nil {
-		return false, call.Err
-	}
-	return call.Body[0].(bool), nil
+	return p.CmdSelectAudioContext(context.Background(), index)
+}
+
+// CmdSelectAudioContext is CmdSelectAudio honoring ctx.
+func (p *Player) CmdSelectAudioContext(ctx context.Context, index int32) (bool, error) {
+	return dbusCallValueContext[bool](ctx, p, cmdSelectAudio, index)
 }
 
 // ShowSubtitles starts displaying subtitles. See
 // https://github.com/popcornmix/omxplayer#showsubtitles for more details.
 func (p *Player) CmdShowSubtitles() error {
-	return sutils.DbusCall(p.bus, cmdShowSubtitles)
+	return p.CmdShowSubtitlesContext(context.Background())
+}
+
+// CmdShowSubtitlesContext is CmdShowSubtitles honoring ctx.
+func (p *Player) CmdShowSubtitlesContext(ctx context.Context) error {
+	return p.dbusCallContext(ctx, cmdShowSubtitles)
 }
 
 // HideSubtitles stops displaying subtitles. See
 // https://github.com/popcornmix/omxplayer#hidesubtitles for more details.
 func (p *Player) CmdHideSubtitles() error {
-	return sutils.DbusCall(p.bus, cmdHideSubtitles)
+	return p.CmdHideSubtitlesContext(context.Background())
+}
+
+// CmdHideSubtitlesContext is CmdHideSubtitles honoring ctx.
+func (p *Player) CmdHideSubtitlesContext(ctx context.Context) error {
+	return p.dbusCallContext(ctx, cmdHideSubtitles)
 }
 
 // Action allows for executing keyboard commands. See
@@ -803,5 +1369,10 @@ func (p *Player) CmdAction(action int32) error {
 	//		"path":        cmdAction,
 	//		"paramAction": action,
 	//	}).Debug("omxplayer: dbus call")
-	return p.bus.Call(cmdAction, 0, action).Err
+	return p.CmdActionContext(context.Background(), action)
+}
+
+// CmdActionContext is CmdAction honoring ctx.
+func (p *Player) CmdActionContext(ctx context.Context, action int32) error {
+	return p.dbusCallContext(ctx, cmdAction, action)
 }
@@ -0,0 +1,106 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// playing returns a player playing a clip of the fake omxplayer that lasts
+// a minute.
+func playing(t *testing.T) *goomx.Player {
+	t.Helper()
+	p := newPlayer(t, goomxtest.Script{Duration: time.Minute}, nil)
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4"))
+	p.Play()
+	collect(t, events, goomx.EventStarted, 1)
+	return p
+}
+
+func TestCmdCanSeek(t *testing.T) {
+	p := playing(t)
+	if ok, err := p.CmdCanSeek(); err != nil || !ok {
+		t.Errorf("CmdCanSeek() = %t, %v", ok, err)
+	}
+	if own := calls(t, p); !slices.Contains(own, "org.freedesktop.DBus.Properties.CanSeek") {
+		t.Errorf("CanSeek not read, calls %v", own)
+	}
+}
+
+func TestCmdContext(t *testing.T) {
+	p := playing(t)
+	events := p.Events()
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+	if id, err := p.CmdIdentityContext(ctx); err != nil || id == "" {
+		t.Errorf("CmdIdentityContext() = %q, %v", id, err)
+	}
+	done, cancelDone := context.WithCancel(context.Background())
+	cancelDone()
+	if _, err := p.CmdCanPauseContext(done); !errors.Is(err, context.Canceled) {
+		t.Errorf("CmdCanPauseContext with a canceled context: %v", err)
+	}
+	if err := p.CmdHideSubtitlesContext(done); !errors.Is(err, context.Canceled) {
+		t.Errorf("CmdHideSubtitlesContext with a canceled context: %v", err)
+	}
+	if err := p.CmdStopContext(ctx); err != nil {
+		t.Errorf("CmdStopContext: %v", err)
+	}
+	collect(t, events, goomx.EventFinished, 1)
+}
+
+// TestRemoveKeepsCursor removes entries before and at the playing one, which
+// must neither repeat nor skip an entry.
+func TestRemoveKeepsCursor(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: 300 * time.Millisecond}, nil)
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4", "c.mp4", "d.mp4"))
+	p.Play()
+
+	collect(t, events, goomx.EventStarted, 2) // a, b
+	if !p.RemoveVideoFromPlaylist(0) {
+		t.Fatal("can not remove a.mp4")
+	}
+	if got := paths(collect(t, events, goomx.EventStarted, 1)); !equal(got, []string{"c.mp4"}) {
+		t.Fatalf("started %v after removing a.mp4, want c.mp4", got)
+	}
+	if !p.RemoveVideoFromPlaylist(1) { // c, playing
+		t.Fatal("can not remove c.mp4")
+	}
+	if got := paths(collect(t, events, goomx.EventStarted, 2)); !equal(got, []string{"d.mp4", "b.mp4"}) {
+		t.Errorf("started %v after removing c.mp4, want d.mp4 then b.mp4", got)
+	}
+	if got := p.GetPlaylistWithoutPath(); !equal(got, []string{"b.mp4", "d.mp4"}) {
+		t.Errorf("playlist %v", got)
+	}
+	if p.RemoveVideoFromPlaylist(2) {
+		t.Errorf("removed an entry past the end")
+	}
+}
+
+// TestRemoveFirstPlaying removes the playing first entry, after which the
+// new first one plays, also in ModeOnce.
+func TestRemoveFirstPlaying(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: 300 * time.Millisecond}, func(cfg *goomx.PlayerConfig) {
+		cfg.PlaybackMode = goomx.ModeOnce
+	})
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4", "c.mp4"))
+	p.Play()
+
+	collect(t, events, goomx.EventStarted, 1)
+	if !p.RemoveVideoFromPlaylist(0) {
+		t.Fatal("can not remove a.mp4")
+	}
+	if got := paths(collect(t, events, goomx.EventStarted, 2)); !equal(got, []string{"b.mp4", "c.mp4"}) {
+		t.Errorf("started %v after removing a.mp4, want b.mp4 then c.mp4", got)
+	}
+}
@@ -0,0 +1,186 @@
+//go:build linux
+
+package goomx
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// omxExitQuit is omxplayer's exit status after a quit request (Quit, Stop or
+// the q key), which is not a crash.
+const omxExitQuit = 3
+
+// CrashKind classifies how a playlist entry failed.
+type CrashKind int
+
+const (
+	CrashNone   CrashKind = iota // played to the end or stopped by the player
+	CrashStart                   // the program could not be started or connected to
+	CrashExit                    // the program exited with a non-zero status
+	CrashSignal                  // the program was killed by a signal goomx did not send
+	CrashStall                   // playback froze and the watchdog killed the program
+)
+
+func (k CrashKind) String() string {
+	switch k {
+	case CrashNone:
+		return "none"
+	case CrashStart:
+		return "start"
+	case CrashExit:
+		return "exit"
+	case CrashSignal:
+		return "signal"
+	case CrashStall:
+		return "stall"
+	default:
+		return "unknown"
+	}
+}
+
+// ClassifyCrash returns the kind of failure err, the Err of an EventFinished
+// or EventFailed, describes.
+func ClassifyCrash(err error) CrashKind {
+	var ee *ExitError
+	switch {
+	case err == nil:
+		return CrashNone
+	case errors.Is(err, ErrStalled):
+		return CrashStall
+	case errors.As(err, &ee) && ee.Signal != 0:
+		return CrashSignal
+	case ee != nil:
+		return CrashExit
+	default:
+		return CrashStart
+	}
+}
+
+// crashRecord is the failure history of one playlist entry.
+type crashRecord struct {
+	failures         int // failures in a row
+	quarantinedUntil time.Time
+	quarantined      bool
+}
+
+// recordPlayback updates the failure counters after path played with result
+// err and returns how long to wait before the next entry. An entry failing
+// QuarantineAfter times in a row is quarantined.
+func (p *Player) recordPlayback(path string, err error) time.Duration {
+	if p.ctx.Err() != nil {
+		return 0
+	}
+	kind := ClassifyCrash(err)
+	p.crashMu.Lock()
+	if kind == CrashNone {
+		delete(p.crashes, path)
+		p.crashStreak = 0
+		p.crashMu.Unlock()
+		return 0
+	}
+	p.crashStreak++
+	backoff := p.crashBackoff(p.crashStreak)
+	if errors.Is(err, ErrBinaryNotFound) { // not the entry's fault
+		p.crashMu.Unlock()
+		return backoff
+	}
+	if p.crashes == nil {
+		p.crashes = make(map[string]*crashRecord)
+	}
+	rec := p.crashes[path]
+	if rec == nil {
+		rec = &crashRecord{}
+		p.crashes[path] = rec
+	}
+	rec.failures++
+	quarantine := p.config.QuarantineAfter > 0 && rec.failures >= p.config.QuarantineAfter && !rec.quarantined
+	if quarantine {
+		rec.quarantined = true
+		if p.config.QuarantineFor > 0 {
+			rec.quarantinedUntil = time.Now().Add(p.config.QuarantineFor)
+		}
+	}
+	failures := rec.failures
+	p.crashMu.Unlock()
+	if quarantine {
+		p.emit(PlayerEvent{Type: EventQuarantined, Path: path, Failures: failures, Err: err})
+	}
+	return backoff
+}
+
+// crashBackoff returns the pause after the n-th failure in a row: CrashBackoff
+// doubled for each earlier failure, at most CrashBackoffMax.
+func (p *Player) crashBackoff(n int) time.Duration {
+	d := p.config.CrashBackoff
+	for i := 1; i < n && d < p.config.CrashBackoffMax; i++ {
+		d *= 2
+	}
+	if d > p.config.CrashBackoffMax {
+		d = p.config.CrashBackoffMax
+	}
+	return d
+}
+
+// isQuarantined reports whether path is quarantined. An expired quarantine is
+// lifted, with the failure counter reset.
+func (p *Player) isQuarantined(path string) bool {
+	p.crashMu.Lock()
+	defer p.crashMu.Unlock()
+	rec := p.crashes[path]
+	if rec == nil || !rec.quarantined {
+		return false
+	}
+	if !rec.quarantinedUntil.IsZero() && time.Now().After(rec.quarantinedUntil) {
+		delete(p.crashes, path)
+		return false
+	}
+	return true
+}
+
+// Quarantined returns the playlist entries currently skipped because they
+// failed too many times in a row, sorted.
+func (p *Player) Quarantined() []string {
+	p.crashMu.Lock()
+	paths := make([]string, 0, len(p.crashes))
+	for path := range p.crashes {
+		paths = append(paths, path)
+	}
+	p.crashMu.Unlock()
+	list := paths[:0]
+	for _, path := range paths {
+		if p.isQuarantined(path) {
+			list = append(list, path)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// ClearQuarantine lifts the quarantine of paths, or of every entry if none
+// are given, and resets their failure counters.
+func (p *Player) ClearQuarantine(paths ...string) {
+	p.crashMu.Lock()
+	defer p.crashMu.Unlock()
+	if len(paths) == 0 {
+		p.crashes = nil
+		return
+	}
+	for _, path := range paths {
+		delete(p.crashes, path)
+	}
+}
+
+// sleep waits for d or until the player is closed.
+func (p *Player) sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-p.ctx.Done():
+	}
+}
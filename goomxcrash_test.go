@@ -0,0 +1,73 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+func TestCrashBackoffAndQuarantine(t *testing.T) {
+	const backoff = 200 * time.Millisecond
+	p := newPlayer(t, goomxtest.Script{Duration: 100 * time.Millisecond, ExitCode: 1}, func(cfg *goomx.PlayerConfig) {
+		cfg.CrashBackoff = backoff
+		cfg.CrashBackoffMax = 2 * backoff
+		cfg.QuarantineAfter = 2
+		cfg.QuarantineFor = 0
+	})
+	events := p.Events()
+	list := clips(t, "a.mp4", "b.mp4")
+	p.ConfigureNewPlaylist(list)
+	p.Play()
+
+	var starts, ends []time.Time
+	var quarantined []goomx.PlayerEvent
+	timeout := time.After(eventTimeout)
+	for len(quarantined) < 2 {
+		select {
+		case ev := <-events:
+			switch ev.Type {
+			case goomx.EventStarted:
+				starts = append(starts, ev.Time)
+			case goomx.EventFinished:
+				if kind := goomx.ClassifyCrash(ev.Err); kind != goomx.CrashExit {
+					t.Errorf("%s finished with %v, a crash of kind %s, want exit", ev.Path, ev.Err, kind)
+				}
+				ends = append(ends, ev.Time)
+			case goomx.EventQuarantined:
+				quarantined = append(quarantined, ev)
+			}
+		case <-timeout:
+			t.Fatalf("%d entries quarantined, want 2", len(quarantined))
+		}
+	}
+	p.Stop()
+
+	if got, want := paths(quarantined), []string{"a.mp4", "b.mp4"}; !equal(got, want) {
+		t.Errorf("quarantined %v, want %v", got, want)
+	}
+	for _, ev := range quarantined {
+		if ev.Failures != 2 {
+			t.Errorf("%s quarantined after %d failures, want 2", ev.Path, ev.Failures)
+		}
+	}
+	// the pause after the n-th failure in a row doubles up to the maximum
+	for i, want := range []time.Duration{backoff, 2 * backoff, 2 * backoff} {
+		if i+1 >= len(starts) || i >= len(ends) {
+			t.Fatalf("%d starts and %d ends, want 4 of each", len(starts), len(ends))
+		}
+		if gap := starts[i+1].Sub(ends[i]); gap < want*9/10 {
+			t.Errorf("next entry started %v after failure %d, want at least %v", gap, i+1, want)
+		}
+	}
+	if got := p.Quarantined(); !equal(got, list) {
+		t.Errorf("Quarantined() = %v, want %v", got, list)
+	}
+	p.ClearQuarantine(list[0])
+	if got := p.Quarantined(); !equal(got, list[1:]) {
+		t.Errorf("Quarantined() = %v after clearing a, want %v", got, list[1:])
+	}
+}
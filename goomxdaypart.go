@@ -0,0 +1,309 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sonnt85/gosutils/slogrus"
+)
+
+// Clock tells the time for dayparting. Tests can provide a fake one.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// DaypartRule selects a playlist at certain times, either with a cron
+// expression or with a daily time range.
+type DaypartRule struct {
+	// Playlist is the name of the playlist in DaypartSchedule.Playlists.
+	Playlist string
+	// Cron is a cron expression (minute hour day-of-month month
+	// day-of-week) of the times the playlist starts. It stays selected until
+	// another cron rule starts its own. If set, the fields below are ignored.
+	Cron string
+	// Weekdays are the days the rule applies to, every day if empty.
+	Weekdays []time.Weekday
+	// Start and End are the time of day, "15:04", the rule applies from and
+	// until. An End before Start ends on the next day; both empty is all day.
+	Start, End string
+}
+
+// DaypartOverride replaces the rules for a range of dates, e.g. holidays.
+type DaypartOverride struct {
+	// From and To are the first and last day of the override. Only their
+	// dates are used, as days in the clock's location.
+	From, To time.Time
+	// Rules apply instead of the schedule's during the override.
+	Rules []DaypartRule
+	// Playlist is selected when none of Rules is, if not empty.
+	Playlist string
+}
+
+// DaypartSchedule maps times of day and days to named playlists.
+type DaypartSchedule struct {
+	// Playlists are the playlists by name.
+	Playlists map[string][]PlaylistItem
+	// Rules are checked in order; the first time range rule that applies
+	// wins, else the cron rule that started last.
+	Rules []DaypartRule
+	// Overrides replace Rules on their dates; the first that applies wins.
+	Overrides []DaypartOverride
+	// Default is the playlist selected when no rule is. If empty, the
+	// playlist is left as it is.
+	Default string
+	// WaitForClip lets the playing clip finish before a new playlist starts,
+	// instead of stopping it. A live stream may never finish.
+	WaitForClip bool
+	// Clock is the time source, the system clock if nil.
+	Clock Clock
+}
+
+// daypartRule is a DaypartRule with its times parsed.
+type daypartRule struct {
+	DaypartRule
+	cron       *cronSpec
+	start, end int // minutes since midnight
+	allDay     bool
+}
+
+// Dayparting switches a player's playlist by a DaypartSchedule; see
+// StartDayparting.
+type Dayparting struct {
+	p         *Player
+	playlists map[string][]PlaylistItem
+	rules     []daypartRule
+	overrides []daypartOverride
+	dflt      string
+	wait      bool
+	clock     Clock
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mu     sync.Mutex
+	active string
+}
+
+type daypartOverride struct {
+	from, to time.Time // midnight of the first day and of the day after the last
+	rules    []daypartRule
+	playlist string
+}
+
+// StartDayparting switches the playlist of the player to the one s selects
+// now and then whenever the selection changes, checking at the start of every
+// minute, until Stop is called or the player is closed. Playlist settings
+// are applied as by ConfigureNewPlaylistItems. It fails with ErrClosed if the
+// player is closed.
+func (p *Player) StartDayparting(s DaypartSchedule) (*Dayparting, error) {
+	if p.ctx.Err() != nil {
+		return nil, &PlayerError{Op: "dayparting", Err: ErrClosed}
+	}
+	d := &Dayparting{p: p, playlists: make(map[string][]PlaylistItem, len(s.Playlists)), dflt: s.Default, wait: s.WaitForClip, clock: s.Clock}
+	if d.clock == nil {
+		d.clock = systemClock{}
+	}
+	for name, items := range s.Playlists {
+		d.playlists[name] = append([]PlaylistItem(nil), items...)
+	}
+	invalid := func(format string, a ...interface{}) error {
+		return &PlayerError{Op: "dayparting", Err: fmt.Errorf(format, a...)}
+	}
+	if _, ok := s.Playlists[s.Default]; s.Default != "" && !ok {
+		return nil, invalid("unknown default playlist %q", s.Default)
+	}
+	for _, items := range s.Playlists {
+		for i := range items {
+			if err := items[i].validate(); err != nil {
+				return nil, &PlayerError{Op: "dayparting", Path: items[i].Path, Err: err}
+			}
+		}
+	}
+	var err error
+	if d.rules, err = d.parseRules(s.Rules); err != nil {
+		return nil, invalid("%w", err)
+	}
+	loc := d.clock.Now().Location()
+	for _, o := range s.Overrides {
+		from := time.Date(o.From.Year(), o.From.Month(), o.From.Day(), 0, 0, 0, 0, loc)
+		to := time.Date(o.To.Year(), o.To.Month(), o.To.Day()+1, 0, 0, 0, 0, loc)
+		if !from.Before(to) {
+			return nil, invalid("override ends before it starts")
+		}
+		if _, ok := s.Playlists[o.Playlist]; o.Playlist != "" && !ok {
+			return nil, invalid("unknown playlist %q", o.Playlist)
+		}
+		rules, err := d.parseRules(o.Rules)
+		if err != nil {
+			return nil, invalid("%w", err)
+		}
+		d.overrides = append(d.overrides, daypartOverride{from: from, to: to, rules: rules, playlist: o.Playlist})
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	d.cancel = cancel
+	d.done = make(chan struct{})
+	d.apply()
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(d.done)
+		d.run(ctx)
+	}()
+	return d, nil
+}
+
+// Stop stops switching playlists. The playlist is left as it is.
+func (d *Dayparting) Stop() {
+	d.cancel()
+	<-d.done
+}
+
+// Active returns the name of the playlist selected last, or "" if none.
+func (d *Dayparting) Active() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.active
+}
+
+// parseRules checks rules and parses their times.
+func (d *Dayparting) parseRules(rules []DaypartRule) ([]daypartRule, error) {
+	parsed := make([]daypartRule, len(rules))
+	for i, r := range rules {
+		if _, ok := d.playlists[r.Playlist]; !ok {
+			return nil, fmt.Errorf("unknown playlist %q", r.Playlist)
+		}
+		pr := daypartRule{DaypartRule: r}
+		var err error
+		switch {
+		case r.Cron != "":
+			pr.cron, err = parseCron(r.Cron)
+		case r.Start == "" && r.End == "":
+			pr.allDay = true
+		default:
+			if pr.start, err = timeOfDay(r.Start); err == nil {
+				pr.end, err = timeOfDay(r.End)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rule for playlist %q: %w", r.Playlist, err)
+		}
+		parsed[i] = pr
+	}
+	return parsed, nil
+}
+
+// timeOfDay parses "15:04" into minutes since midnight. "" is midnight.
+func timeOfDay(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// run applies the schedule at the start of every minute.
+func (d *Dayparting) run(ctx context.Context) {
+	for {
+		now := d.clock.Now()
+		wait := now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.clock.After(wait):
+		}
+		d.apply()
+	}
+}
+
+// apply switches to the playlist selected now, if it is not already.
+func (d *Dayparting) apply() {
+	name := d.activeAt(d.clock.Now())
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if name == "" || name == d.active {
+		return
+	}
+	d.active = name
+	slogrus.Print("Dayparting: switch to playlist ", name)
+	if _, err := d.p.configureNewPlaylistItems(d.playlists[name], !d.wait); err != nil {
+		slogrus.Error("Can not switch to playlist ", name, ": ", err)
+	}
+}
+
+// activeAt returns the name of the playlist selected at t.
+func (d *Dayparting) activeAt(t time.Time) string {
+	for _, o := range d.overrides {
+		if o.from.After(t) || !t.Before(o.to) {
+			continue
+		}
+		if name := selectRule(o.rules, t); name != "" {
+			return name
+		}
+		if o.playlist != "" {
+			return o.playlist
+		}
+	}
+	if name := selectRule(d.rules, t); name != "" {
+		return name
+	}
+	return d.dflt
+}
+
+// selectRule returns the playlist of the first time range rule that applies
+// at t, else of the cron rule that started last, or "".
+func selectRule(rules []daypartRule, t time.Time) string {
+	var latest time.Time
+	name := ""
+	for _, r := range rules {
+		if r.cron != nil {
+			if at, ok := r.cron.lastFire(t); ok && at.After(latest) {
+				latest, name = at, r.Playlist
+			}
+			continue
+		}
+		if r.applies(t) {
+			return r.Playlist
+		}
+	}
+	return name
+}
+
+// applies reports whether the time range rule r applies at t. The part of a
+// range after midnight belongs to the day it started.
+func (r *daypartRule) applies(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	switch {
+	case r.allDay:
+	case r.start < r.end:
+		if minute < r.start || minute >= r.end {
+			return false
+		}
+	case minute >= r.start: // spans midnight, before it
+	case minute < r.end: // spans midnight, after it
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+	if len(r.Weekdays) == 0 {
+		return true
+	}
+	for _, w := range r.Weekdays {
+		if w == day {
+			return true
+		}
+	}
+	return false
+}
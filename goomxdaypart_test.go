@@ -0,0 +1,210 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// fakeClock is a Clock moved on by the test.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock { return &fakeClock{now: now} }
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Set moves the clock to now, once something waits on it, and fires the
+// waits that are due.
+func (c *fakeClock) Set(t *testing.T, now time.Time) {
+	t.Helper()
+	deadline := time.Now().Add(eventTimeout)
+	for {
+		c.mu.Lock()
+		if len(c.waiters) > 0 {
+			break
+		}
+		c.mu.Unlock()
+		if time.Now().After(deadline) {
+			t.Fatal("nothing waits on the clock")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	defer c.mu.Unlock()
+	c.now = now
+	waiting := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(now) {
+			waiting = append(waiting, w)
+		} else {
+			w.ch <- now
+		}
+	}
+	c.waiters = waiting
+}
+
+// waitActive waits until d has selected the playlist name.
+func waitActive(t *testing.T, d *goomx.Dayparting, name string) {
+	t.Helper()
+	deadline := time.Now().Add(eventTimeout)
+	for d.Active() != name {
+		if time.Now().After(deadline) {
+			t.Fatalf("active playlist %q, want %q", d.Active(), name)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// daypartPlaylists returns one single-entry playlist per name, the entry
+// being the file named after the playlist.
+func daypartPlaylists(t *testing.T, names ...string) map[string][]goomx.PlaylistItem {
+	files := make([]string, len(names))
+	for i, name := range names {
+		files[i] = name + ".mp4"
+	}
+	lists := make(map[string][]goomx.PlaylistItem, len(names))
+	for i, path := range clips(t, files...) {
+		lists[names[i]] = []goomx.PlaylistItem{{Path: path}}
+	}
+	return lists
+}
+
+// playlistName returns the name of the single-entry playlist p plays.
+func playlistName(p *goomx.Player) string {
+	list := p.GetPlaylist()
+	if len(list) != 1 {
+		return ""
+	}
+	return filepath.Base(list[0][:len(list[0])-len(".mp4")])
+}
+
+func TestDaypartingTimeRanges(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	// Monday 1 July 2024
+	clock := newFakeClock(time.Date(2024, 7, 1, 7, 30, 0, 0, time.Local))
+	d, err := p.StartDayparting(goomx.DaypartSchedule{
+		Playlists: daypartPlaylists(t, "morning", "weekend", "party", "holiday", "idle"),
+		Rules: []goomx.DaypartRule{
+			{Playlist: "party", Weekdays: []time.Weekday{time.Friday}, Start: "22:00", End: "02:00"},
+			{Playlist: "weekend", Weekdays: []time.Weekday{time.Saturday, time.Sunday}},
+			{Playlist: "morning", Start: "06:00", End: "12:00"},
+		},
+		Overrides: []goomx.DaypartOverride{{
+			From:     time.Date(2024, 7, 4, 0, 0, 0, 0, time.Local),
+			To:       time.Date(2024, 7, 4, 0, 0, 0, 0, time.Local),
+			Playlist: "holiday",
+		}},
+		Default: "idle",
+		Clock:   clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	waitActive(t, d, "morning")
+	for _, step := range []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2024, 7, 1, 12, 0, 0, 0, time.Local), "idle"},   // no rule applies
+		{time.Date(2024, 7, 4, 9, 0, 0, 0, time.Local), "holiday"}, // Thursday, overridden
+		{time.Date(2024, 7, 5, 9, 0, 0, 0, time.Local), "morning"}, // override over
+		{time.Date(2024, 7, 6, 1, 0, 0, 0, time.Local), "party"},   // Friday's range after midnight
+		{time.Date(2024, 7, 6, 9, 0, 0, 0, time.Local), "weekend"}, // before the morning rule
+		{time.Date(2024, 7, 7, 1, 0, 0, 0, time.Local), "weekend"}, // Saturday has no party
+		{time.Date(2024, 7, 8, 13, 0, 0, 0, time.Local), "idle"},
+	} {
+		clock.Set(t, step.at)
+		waitActive(t, d, step.want)
+		if got := playlistName(p); got != step.want {
+			t.Errorf("at %s playlist is %q, want %q", step.at, got, step.want)
+		}
+	}
+}
+
+func TestDaypartingCron(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	clock := newFakeClock(time.Date(2024, 7, 1, 13, 0, 0, 0, time.Local))
+	d, err := p.StartDayparting(goomx.DaypartSchedule{
+		Playlists: daypartPlaylists(t, "lunch", "afternoon"),
+		Rules: []goomx.DaypartRule{
+			{Playlist: "lunch", Cron: "0 12 * * 1-5"},
+			{Playlist: "afternoon", Cron: "30 14 * * *"},
+		},
+		Clock: clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	waitActive(t, d, "lunch")
+	clock.Set(t, time.Date(2024, 7, 1, 14, 29, 0, 0, time.Local))
+	clock.Set(t, time.Date(2024, 7, 1, 14, 30, 0, 0, time.Local))
+	waitActive(t, d, "afternoon")
+	if got := playlistName(p); got != "afternoon" {
+		t.Errorf("playlist is %q, want afternoon", got)
+	}
+}
+
+func TestDaypartingInvalid(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	lists := daypartPlaylists(t, "a")
+	for _, s := range []goomx.DaypartSchedule{
+		{Playlists: lists, Default: "b"},
+		{Playlists: lists, Rules: []goomx.DaypartRule{{Playlist: "b"}}},
+		{Playlists: lists, Rules: []goomx.DaypartRule{{Playlist: "a", Start: "25:00"}}},
+		{Playlists: lists, Rules: []goomx.DaypartRule{{Playlist: "a", Cron: "* *"}}},
+		{Playlists: lists, Overrides: []goomx.DaypartOverride{{From: time.Now(), To: time.Now().AddDate(0, 0, -1)}}},
+	} {
+		if d, err := p.StartDayparting(s); err == nil {
+			d.Stop()
+			t.Errorf("StartDayparting accepted %+v", s)
+		}
+	}
+}
+
+func TestDaypartingClosed(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	lists := daypartPlaylists(t, "a")
+	if _, err := p.StartDayparting(goomx.DaypartSchedule{Playlists: lists, Default: "a"}); !errors.Is(err, goomx.ErrClosed) {
+		t.Errorf("StartDayparting after Close: %v, want ErrClosed", err)
+	}
+	if got := p.GetPlaylist(); len(got) != 0 {
+		t.Errorf("playlist changed to %v after Close", got)
+	}
+}
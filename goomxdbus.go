@@ -0,0 +1,108 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+)
+
+// dbusCallTimeout bounds D-Bus calls made without a deadline, so a wedged
+// omxplayer cannot hang the caller.
+const dbusCallTimeout = 5 * time.Second
+
+// busObject returns the bus object of the omxplayer process that is currently
+// playing, or nil. It is set and cleared with the session.
+func (p *Player) busObject() dbus.BusObject {
+	p.busMu.RLock()
+	defer p.busMu.RUnlock()
+	return p.bus
+}
+
+// dbusDo is the single path every D-Bus method call to omxplayer goes
+// through. It fails with ErrClosed once the player is closing, with
+// ErrNotRunning when no process is playing, or with ErrNotSupported when
+// another backend is playing, honors cancellation and deadline of ctx
+// (bounded by dbusCallTimeout if ctx has no deadline, an expired one matching
+// ErrDbusTimeout) and classifies call errors with dbusError.
+func (p *Player) dbusDo(ctx context.Context, method string, args ...interface{}) (*dbus.Call, error) {
+	if p.ctx.Err() != nil {
+		return nil, &PlayerError{Op: method, Err: ErrClosed}
+	}
+	bus := p.busObject()
+	if bus == nil && p.currentSession() != nil {
+		return nil, &PlayerError{Op: method, Err: ErrNotSupported}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(p.ctx, cancel)() // Close cuts the call short
+	call, err := dbusDoOn(ctx, bus, method, args...)
+	p.closedError(&err)
+	return call, err
+}
+
+// dbusDoOn is dbusDo for a specific bus object, such as a preloaded process.
+func dbusDoOn(ctx context.Context, bus dbus.BusObject, method string, args ...interface{}) (*dbus.Call, error) {
+	if bus == nil {
+		return nil, &PlayerError{Op: method, Err: ErrNotRunning}
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dbusCallTimeout)
+		defer cancel()
+	}
+	call := bus.Go(method, 0, make(chan *dbus.Call, 1), args...)
+	select {
+	case <-call.Done:
+	case <-ctx.Done():
+		return nil, &PlayerError{Op: method, Err: deadlineError(ctx)}
+	}
+	if call.Err != nil {
+		return nil, dbusError(method, call.Err)
+	}
+	return call, nil
+}
+
+// dbusCall calls a D-Bus method and ignores its reply.
+func (p *Player) dbusCall(method string, args ...interface{}) error {
+	return p.dbusCallContext(context.Background(), method, args...)
+}
+
+// dbusCallContext is dbusCall honoring ctx.
+func (p *Player) dbusCallContext(ctx context.Context, method string, args ...interface{}) error {
+	_, err := p.dbusDo(ctx, method, args...)
+	return err
+}
+
+// dbusCallValueContext calls a D-Bus method and returns the first value of
+// its reply, which must be of type T.
+func dbusCallValueContext[T any](ctx context.Context, p *Player, method string, args ...interface{}) (T, error) {
+	call, err := p.dbusDo(ctx, method, args...)
+	return dbusValue[T](method, call, err)
+}
+
+// dbusValueOn is dbusCallValueContext for a specific bus object.
+func dbusValueOn[T any](ctx context.Context, bus dbus.BusObject, method string, args ...interface{}) (T, error) {
+	call, err := dbusDoOn(ctx, bus, method, args...)
+	return dbusValue[T](method, call, err)
+}
+
+// dbusValue returns the first value of the reply of call, which must be of
+// type T.
+func dbusValue[T any](method string, call *dbus.Call, err error) (T, error) {
+	var zero T
+	if err != nil {
+		return zero, err
+	}
+	if len(call.Body) == 0 {
+		return zero, &PlayerError{Op: method, Err: fmt.Errorf("%w: empty reply", ErrUnexpectedReply)}
+	}
+	v, ok := call.Body[0].(T)
+	if !ok {
+		return zero, &PlayerError{Op: method, Err: fmt.Errorf("%w: got %T, want %T", ErrUnexpectedReply, call.Body[0], zero)}
+	}
+	return v, nil
+}
@@ -0,0 +1,147 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/sonnt85/gosutils/sutils"
+)
+
+var (
+	// ErrNotRunning is returned by control methods when no omxplayer process
+	// is playing, or the process went away while the call was in flight.
+	ErrNotRunning = errors.New("omxplayer is not running")
+	// ErrDbusTimeout is returned when omxplayer did not publish its D-Bus
+	// address, or answer a D-Bus call, in time.
+	ErrDbusTimeout = errors.New("timed out waiting for omxplayer dbus")
+	// ErrClosed is returned when the player is closed while waiting for
+	// omxplayer, and by control methods called during or after Close.
+	ErrClosed = errors.New("player is closed")
+	// ErrBinaryNotFound is returned when the omxplayer (or omxiv) executable
+	// cannot be found.
+	ErrBinaryNotFound = errors.New("player binary not found")
+	// ErrFileMissing is returned when a playlist entry is neither an existing
+	// file nor a stream.
+	ErrFileMissing = errors.New("file does not exist")
+	// ErrQuarantined is reported for a playlist entry skipped because it
+	// failed too many times in a row; see Player.Quarantined.
+	ErrQuarantined = errors.New("file is quarantined after repeated failures")
+	// ErrStalled is reported for playback killed by the watchdog because the
+	// position stopped advancing or the player stopped answering; see
+	// PlayerConfig.StallTimeout.
+	ErrStalled = errors.New("playback stalled")
+	// ErrPlaylistFormat is returned for a playlist file that cannot be
+	// parsed or has an unknown format.
+	ErrPlaylistFormat = errors.New("malformed playlist file")
+	// ErrPlaybackCrashed is returned when omxplayer exits abnormally on its own.
+	ErrPlaybackCrashed = errors.New("playback crashed")
+	// ErrUnexpectedReply is returned when a D-Bus reply does not have the
+	// expected type.
+	ErrUnexpectedReply = errors.New("unexpected dbus reply")
+	// ErrNotSupported is returned by control methods the playing backend
+	// does not implement.
+	ErrNotSupported = errors.New("not supported by the player backend")
+)
+
+// PlayerError records the operation and playlist entry an error happened for.
+type PlayerError struct {
+	Op   string // operation, e.g. "start", "dbus connect" or a D-Bus method
+	Path string // playlist entry, if any
+	Err  error
+}
+
+func (e *PlayerError) Error() string {
+	if e.Path == "" {
+		return "goomx: " + e.Op + ": " + e.Err.Error()
+	}
+	return "goomx: " + e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *PlayerError) Unwrap() error { return e.Err }
+
+// ExitError describes an omxplayer process that exited abnormally. It matches
+// ErrPlaybackCrashed with errors.Is.
+type ExitError struct {
+	Path     string
+	ExitCode int            // -1 if the process was killed by a signal
+	Signal   syscall.Signal // signal that killed the process, if any
+}
+
+func (e *ExitError) Error() string {
+	if e.Signal != 0 {
+		return fmt.Sprintf("goomx: playback of %s crashed: %s", e.Path, e.Signal)
+	}
+	return fmt.Sprintf("goomx: playback of %s crashed: exit status %d", e.Path, e.ExitCode)
+}
+
+func (e *ExitError) Is(target error) bool { return target == ErrPlaybackCrashed }
+
+// newExitError builds an ExitError from the state of a process that has been
+// waited for.
+func newExitError(path string, state *os.ProcessState) *ExitError {
+	e := &ExitError{Path: path, ExitCode: state.ExitCode()}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		e.Signal = ws.Signal()
+	}
+	return e
+}
+
+// startError classifies an error from starting a player binary.
+func startError(path string, err error) error {
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		err = fmt.Errorf("%w: %v", ErrBinaryNotFound, err)
+	}
+	return &PlayerError{Op: "start", Path: path, Err: err}
+}
+
+// deadlineError returns the error of a done ctx, matching ErrDbusTimeout as
+// well if its deadline expired.
+func deadlineError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrDbusTimeout, ctx.Err())
+	}
+	return ctx.Err()
+}
+
+// closedError makes *err match ErrClosed as well if the player has been
+// closed, so calls cut short by Close report it whatever failed first.
+func (p *Player) closedError(err *error) {
+	if *err == nil || p.ctx.Err() == nil || errors.Is(*err, ErrClosed) {
+		return
+	}
+	if pe, ok := (*err).(*PlayerError); ok {
+		e := *pe
+		e.Err = fmt.Errorf("%w: %w", ErrClosed, pe.Err)
+		*err = &e
+		return
+	}
+	*err = fmt.Errorf("%w: %w", ErrClosed, *err)
+}
+
+// dbusError classifies an error from a D-Bus call to omxplayer. Errors caused
+// by the player not running (no bus object yet, closed connection, name not
+// owned) are reported as ErrNotRunning.
+func dbusError(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var derr dbus.Error
+	switch {
+	case errors.Is(err, sutils.ErrDusObjectIsNil), errors.Is(err, dbus.ErrClosed):
+		err = ErrNotRunning
+	case errors.As(err, &derr) && (derr.Name == "org.freedesktop.DBus.Error.ServiceUnknown" ||
+		derr.Name == "org.freedesktop.DBus.Error.NoReply"):
+		err = fmt.Errorf("%w: %v", ErrNotRunning, err)
+	case strings.Contains(err.Error(), "use of closed network connection"):
+		err = fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	return &PlayerError{Op: method, Err: err}
+}
@@ -0,0 +1,36 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sonnt85/goomx"
+)
+
+func TestCallsAfterCloseFailWithErrClosed(t *testing.T) {
+	p := playing(t)
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, canSeek := p.CmdCanSeekContext(ctx)
+	_, position := p.PositionContext(ctx)
+	for name, err := range map[string]error{
+		"CmdCanSeekContext": canSeek,
+		"PositionContext":   position,
+		"CmdPauseContext":   p.CmdPauseContext(ctx),
+		"CmdHideVideo":      p.CmdHideVideo(),
+	} {
+		if !errors.Is(err, goomx.ErrClosed) {
+			t.Errorf("%s after Close: %v, want ErrClosed", name, err)
+		}
+		var pe *goomx.PlayerError
+		if !errors.As(err, &pe) {
+			t.Errorf("%s after Close: %v is not a PlayerError", name, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+//go:build linux
+
+package goomx
+
+import (
+	"slices"
+	"time"
+)
+
+// EventType identifies the kind of transition reported by a PlayerEvent.
+type EventType int
+
+const (
+	EventStarted       EventType = iota + 1 // omxplayer process started for a file
+	EventFinished                           // omxplayer process exited
+	EventFailed                             // file could not be played
+	EventSkipped                            // file was skipped without being started
+	EventPaused                             // playback was paused
+	EventResumed                            // playback was resumed
+	EventVolumeChanged                      // volume was changed
+	EventQuarantined                        // file failed too often and will be skipped
+	EventStalled                            // playback froze and the process is killed
+	EventPlaylistEnded                      // last entry ended in ModeOnce, playback stopped
+)
+
+// eventsBufferSize is the channel capacity of each subscription. Events are
+// dropped for subscribers that fall this far behind.
+const eventsBufferSize = 64
+
+func (t EventType) String() string {
+	switch t {
+	case EventStarted:
+		return "started"
+	case EventFinished:
+		return "finished"
+	case EventFailed:
+		return "failed"
+	case EventSkipped:
+		return "skipped"
+	case EventPaused:
+		return "paused"
+	case EventResumed:
+		return "resumed"
+	case EventVolumeChanged:
+		return "volume_changed"
+	case EventQuarantined:
+		return "quarantined"
+	case EventStalled:
+		return "stalled"
+	case EventPlaylistEnded:
+		return "playlist_ended"
+	default:
+		return "unknown"
+	}
+}
+
+// PlayerEvent describes a single playback transition of a Player.
+type PlayerEvent struct {
+	Type EventType
+	// Time is when the event was emitted.
+	Time time.Time
+	// Path is the playlist entry the event refers to.
+	Path string
+	// Index is the position of Path in the playlist, or -1 if it is no longer
+	// in the playlist.
+	Index int
+	// StartTime is when the omxplayer process for Path was started. It is zero
+	// for events emitted before the process started.
+	StartTime time.Time
+	// Duration is how long Path was played, set for EventFinished.
+	Duration time.Duration
+	// ExitCode is the omxplayer exit code, set for EventFinished. It is -1 if
+	// the process was killed by a signal.
+	ExitCode int
+	// Interrupted reports whether playback was stopped by the player (Stop,
+	// seek, new playlist, Close) rather than ending on its own.
+	Interrupted bool
+	// Volume is the new volume, set for EventVolumeChanged.
+	Volume float64
+	// Failures is how many times in a row Path failed, set for
+	// EventQuarantined.
+	Failures int
+	// Err is the error that caused the event, if any.
+	Err error
+}
+
+// Events returns a new subscription to the player's event stream. Each call
+// returns a separate channel; events are delivered without blocking the
+// player, so a subscriber that does not keep up loses events. The channel is
+// closed by Unsubscribe or Close.
+func (p *Player) Events() <-chan PlayerEvent {
+	ch := make(chan PlayerEvent, eventsBufferSize)
+	p.eventsMu.Lock()
+	defer p.eventsMu.Unlock()
+	if p.ctx.Err() != nil {
+		close(ch)
+		return ch
+	}
+	if p.subscribers == nil {
+		p.subscribers = make(map[<-chan PlayerEvent]chan PlayerEvent)
+	}
+	p.subscribers[ch] = ch
+	return ch
+}
+
+// Unsubscribe stops delivery to a channel returned by Events and closes it.
+func (p *Player) Unsubscribe(ch <-chan PlayerEvent) {
+	p.eventsMu.Lock()
+	defer p.eventsMu.Unlock()
+	if c, ok := p.subscribers[ch]; ok {
+		delete(p.subscribers, ch)
+		close(c)
+	}
+}
+
+// closeSubscribers closes every subscription and stops recording proof of
+// play, used by Close once the player's goroutines have exited.
+func (p *Player) closeSubscribers() {
+	p.eventsMu.Lock()
+	defer p.eventsMu.Unlock()
+	p.eventsClosed = true
+	for k, c := range p.subscribers {
+		delete(p.subscribers, k)
+		close(c)
+	}
+}
+
+// emit fills in the event time and playlist index and delivers ev to every
+// subscriber that has room for it.
+func (p *Player) emit(ev PlayerEvent) {
+	ev.Time = time.Now()
+	list, _ := p.Copy()
+	// duplicate entries cannot be told apart, so the first one is reported
+	ev.Index = slices.Index(list, ev.Path)
+	p.eventsMu.Lock()
+	defer p.eventsMu.Unlock()
+	for _, c := range p.subscribers {
+		select {
+		case c <- ev:
+		default:
+		}
+	}
+	if rec := p.proofOfPlay; rec != nil && !p.eventsClosed && ev.Type == EventFinished {
+		p.popQueue = append(p.popQueue, proofOfPlayJob{rec: rec, ev: ev})
+		if !p.popWriting { // checksumming a large clip must not delay the next one
+			p.popWriting = true
+			p.recording.Add(1)
+			go p.writeProofOfPlay()
+		}
+	}
+}
+
+// emitPlaying emits an event of type t for the file that is currently playing.
+func (p *Player) emitPlaying(t EventType, err error) {
+	fp, started := p.nowPlaying()
+	p.emit(PlayerEvent{Type: t, Path: fp.pathFile, StartTime: started, Err: err})
+}
+
+// emitPlaybackStatus reads the playback status after a pause/resume toggle and
+// emits the matching event.
+func (p *Player) emitPlaybackStatus() {
+	if status, err := p.CmdPlaybackStatus(); err == nil {
+		if status == "Paused" {
+			p.emitPlaying(EventPaused, nil)
+		} else {
+			p.emitPlaying(EventResumed, nil)
+		}
+	}
+}
+
+// setNowPlaying records the file being played and when it started.
+func (p *Player) setNowPlaying(fp FilePlay, started time.Time) {
+	p.eventsMu.Lock()
+	defer p.eventsMu.Unlock()
+	p.playing = fp
+	p.playingSince = started
+}
+
+// nowPlaying returns the file being played and when it started.
+func (p *Player) nowPlaying() (FilePlay, time.Time) {
+	p.eventsMu.Lock()
+	defer p.eventsMu.Unlock()
+	return p.playing, p.playingSince
+}
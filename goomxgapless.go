@@ -0,0 +1,300 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/sonnt85/gosutils/slogrus"
+	"github.com/sonnt85/gosutils/sutils"
+)
+
+const (
+	// defaultGaplessLayer is the display layer clips play on in gapless mode.
+	defaultGaplessLayer = 2
+	// defaultGaplessPreroll is how long before the end of a clip the next one
+	// is preloaded.
+	defaultGaplessPreroll = 3 * time.Second
+	// preloadPollInterval is how often the position of the current clip is
+	// checked while waiting to preload.
+	preloadPollInterval = 250 * time.Millisecond
+	// preloadDbusSuffix is appended to the player's D-Bus name for the
+	// alternate process in gapless mode.
+	preloadDbusSuffix = "_b"
+)
+
+// omxProcess is an omxplayer process started ahead of time, paused on a lower
+// layer, waiting to become the current clip.
+type omxProcess struct {
+	cmd     *exec.Cmd
+	keys    io.WriteCloser // standard input of cmd
+	name    string         // D-Bus name
+	path    string
+	conn    *dbus.Conn
+	bus     dbus.BusObject
+	cancel  context.CancelFunc // stops the name watcher
+	exited  chan struct{}      // closed once cmd has been waited for
+	waitErr error
+}
+
+// startOmxProcess starts omxplayer as execOmxplayer does and waits for it in
+// the background, so that an early exit is noticed.
+func startOmxProcess(binary string, env []string, url string, args ...string) (*omxProcess, error) {
+	cmd, keys, err := execOmxplayer(binary, env, url, args...)
+	if err != nil {
+		return nil, err
+	}
+	op := &omxProcess{cmd: cmd, keys: keys, exited: make(chan struct{})}
+	go func() {
+		op.waitErr = cmd.Wait()
+		close(op.exited)
+	}()
+	return op, nil
+}
+
+// wait waits for the process to exit and returns its exit error.
+func (op *omxProcess) wait() error {
+	<-op.exited
+	return op.waitErr
+}
+
+// hasExited reports whether the process has exited.
+func (op *omxProcess) hasExited() bool {
+	select {
+	case <-op.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// kill kills the process group, waits for it and closes its connection.
+func (op *omxProcess) kill() {
+	op.cancel()
+	if op.cmd.Process != nil {
+		syscall.Kill(-op.cmd.Process.Pid, syscall.SIGKILL)
+	}
+	op.wait()
+	if op.conn != nil {
+		op.conn.Close()
+	}
+}
+
+// SetGapless enables or disables double-buffered transitions. When enabled,
+// clips play on display layer layer and, preroll before the current clip ends,
+// the next playlist entry is started paused on layer-1; when the current clip
+// ends the preloaded one is raised and unpaused instead of starting a new
+// process, so there is no black gap. Gapless mode controls omxplayer's
+// --layer argument, so do not pass it in the player args. Streams are never
+// preloaded. A preroll or layer <= 0 selects the default.
+func (p *Player) SetGapless(enabled bool, preroll time.Duration, layer int) {
+	if preroll <= 0 {
+		preroll = defaultGaplessPreroll
+	}
+	if layer <= 0 {
+		layer = defaultGaplessLayer
+	}
+	p.gaplessMu.Lock()
+	p.gapless = enabled
+	p.gaplessPreroll = preroll
+	p.gaplessLayer = layer
+	var discard *omxProcess
+	if !enabled {
+		discard, p.preloaded = p.preloaded, nil
+	}
+	p.gaplessMu.Unlock()
+	if discard != nil {
+		discard.kill()
+	}
+}
+
+// gaplessSettings returns whether gapless mode is on, its preroll and layer.
+func (p *Player) gaplessSettings() (bool, time.Duration, int) {
+	p.gaplessMu.Lock()
+	defer p.gaplessMu.Unlock()
+	return p.gapless, p.gaplessPreroll, p.gaplessLayer
+}
+
+// hasPreloaded reports whether a preloaded process is waiting.
+func (p *Player) hasPreloaded() bool {
+	p.gaplessMu.Lock()
+	defer p.gaplessMu.Unlock()
+	return p.preloaded != nil
+}
+
+// takePreloaded returns the preloaded process if it is for path. A preloaded
+// process for any other file (the playlist changed or was seeked) is killed.
+func (p *Player) takePreloaded(path string) *omxProcess {
+	p.gaplessMu.Lock()
+	pre := p.preloaded
+	p.preloaded = nil
+	p.gaplessMu.Unlock()
+	if pre == nil {
+		return nil
+	}
+	if pre.path != path || pre.hasExited() {
+		pre.kill()
+		return nil
+	}
+	return pre
+}
+
+// omxArgs returns the omxplayer arguments, without the file, to play file
+// under D-Bus name name on display layer layer, followed by args. A layer < 0
+// leaves the layer to omxplayer.
+func (p *Player) omxArgs(file FilePlay, name string, layer int, args []string) []string {
+	list := make([]string, 0)
+	if name != ifaceOmx {
+		list = append(list, "--dbus_name", name)
+	}
+	if layer >= 0 {
+		list = append(list, "--layer", strconv.Itoa(layer))
+	}
+	if file.start > 0 {
+		list = append(list, "--pos", formatPosition(file.start))
+	}
+	if p.config.Display != 0 {
+		list = append(list, "--display", strconv.Itoa(p.config.Display))
+	}
+	if file.isStreamLink {
+		list = append(list, p.streamArgs(file.pathFile)...)
+	}
+	return append(list, args...)
+}
+
+// alternateName returns the D-Bus name not used by the process named active.
+func (p *Player) alternateName(active string) string {
+	if active == p.dbusName {
+		return p.dbusName + preloadDbusSuffix
+	}
+	return p.dbusName
+}
+
+// preloadNext watches the position of session s, playing file, and preloads
+// the next playlist entry once the clip is within the preroll of its end or
+// of its maximum play time. It returns when ctx (the current playback) is
+// done or the entry has been preloaded.
+func (p *Player) preloadNext(ctx context.Context, s *omxSession, file FilePlay) {
+	ticker := time.NewTicker(preloadPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		enabled, preroll, layer := p.gaplessSettings()
+		if !enabled {
+			return
+		}
+		pos, err := s.Position(ctx)
+		if err != nil {
+			continue
+		}
+		dur, err := s.Duration(ctx)
+		if err != nil || dur <= 0 {
+			continue
+		}
+		// omxplayer reports position and duration in microseconds
+		if file.maxPlay > 0 {
+			if end := (file.start + file.maxPlay).Microseconds(); end < dur {
+				dur = end
+			}
+		}
+		if time.Duration(dur-pos)*time.Microsecond > preroll {
+			continue
+		}
+		if file.loops > 0 { // the same clip plays again
+			return
+		}
+		next, ok := p.peekNext()
+		if !ok {
+			return
+		}
+		nextFile := p.filePlay(next)
+		if nextFile.isStreamLink || !sutils.PathIsFile(nextFile.location) || p.isQuarantined(next) {
+			return
+		}
+		pre, err := p.preload(nextFile, p.alternateName(s.name), layer-1)
+		if err != nil {
+			slogrus.Print("can not preload ", next, ": ", err)
+			return
+		}
+		// gapless mode may have been turned off, or the clip ended, while
+		// preloading; nothing would take pre then
+		p.gaplessMu.Lock()
+		if !p.gapless || ctx.Err() != nil || p.ctx.Err() != nil {
+			p.gaplessMu.Unlock()
+			pre.kill()
+			return
+		}
+		discard := p.preloaded
+		p.preloaded = pre
+		p.gaplessMu.Unlock()
+		if discard != nil {
+			discard.kill()
+		}
+		return
+	}
+}
+
+// preload starts file paused on layer under D-Bus name name and connects to
+// it.
+func (p *Player) preload(file FilePlay, name string, layer int) (pre *omxProcess, err error) {
+	pre, err = startOmxProcess(p.config.OmxplayerBinary, p.processEnv(), file.location, p.omxArgs(file, name, layer, p.clipArgs(file))...)
+	if err != nil {
+		return nil, err
+	}
+	io.WriteString(pre.keys, keyPause)
+	ctx, cancel := context.WithCancel(p.ctx)
+	pre.name, pre.path, pre.cancel = name, file.pathFile, cancel
+	defer func() {
+		if err != nil {
+			pre.kill()
+			pre = nil
+		}
+	}()
+	if pre.conn, err = p.getDbusConnection(); err != nil {
+		return
+	}
+	pre.bus = pre.conn.Object(name, pathMpris)
+	ready := newReadyFlag()
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		watchBusName(ctx, pre.conn, name, ready)
+	}()
+	readyCtx, cancelReady := context.WithTimeout(ctx, dbusCallTimeout)
+	defer cancelReady()
+	select {
+	case <-ready.wait(true):
+	case <-readyCtx.Done():
+		return pre, &PlayerError{Op: "preload", Path: file.pathFile, Err: deadlineError(readyCtx)}
+	}
+	// stdin already paused it; ACTION_PAUSE makes sure without toggling
+	_, err = dbusDoOn(readyCtx, pre.bus, cmdAction, int32(ACTION_PAUSE))
+	return
+}
+
+// peekNext returns the playlist entry that will be played after the current
+// one without moving through the playlist.
+func (p *Player) peekNext() (string, bool) {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	r, n := p.cursor()
+	if n == 0 || p.interrupting || len(p.resume) > 0 {
+		return "", false
+	}
+	i, err := p.nextIndex(r, n)
+	list, _ := p.Copy()
+	if err != nil || i >= len(list) {
+		return "", false
+	}
+	return list[i], true
+}
@@ -0,0 +1,79 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// gapless returns a gapless player of clips lasting d that preloads the next
+// clip as soon as the current one starts, and its events.
+func gapless(t *testing.T, d time.Duration) (*goomx.Player, <-chan goomx.PlayerEvent) {
+	t.Helper()
+	p := newPlayer(t, goomxtest.Script{Duration: d}, nil)
+	p.SetGapless(true, time.Minute, 0)
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4"))
+	p.Play()
+	return p, events
+}
+
+// waitPreloaded waits until the player has paused the process preloaded
+// under its alternate D-Bus name.
+func waitPreloaded(t *testing.T, p *goomx.Player) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+	if err := harness.WaitForCall(ctx, p.DbusName()+"_b org.mpris.MediaPlayer2.Player.Action"); err != nil {
+		t.Fatal("next clip not preloaded: ", err)
+	}
+}
+
+func TestGapless(t *testing.T) {
+	p, events := gapless(t, time.Second)
+	waitPreloaded(t, p)
+	started := collect(t, events, goomx.EventStarted, 2)
+	if got, want := paths(started), []string{"a.mp4", "b.mp4"}; !equal(got, want) {
+		t.Errorf("started %v, want %v", got, want)
+	}
+	all, err := harness.Calls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(all, p.DbusName()+"_b org.mpris.MediaPlayer2.Player.SetLayer 2") {
+		t.Errorf("preloaded clip not raised, calls %v", all)
+	}
+}
+
+// TestGaplessPreloadedExited quits the preloaded process before it is
+// needed; the next clip must then be started anew.
+func TestGaplessPreloadedExited(t *testing.T) {
+	p, events := gapless(t, 2*time.Second)
+	waitPreloaded(t, p)
+	conn, err := dbus.Dial(harness.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err = conn.Auth(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.Hello(); err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.Object(p.DbusName()+"_b", "/org/mpris/MediaPlayer2").Call("org.mpris.MediaPlayer2.Quit", 0).Err; err != nil {
+		t.Fatal(err)
+	}
+
+	finished := collect(t, events, goomx.EventFinished, 2)
+	if b := finished[1]; b.ExitCode != 0 || b.Duration < time.Second {
+		t.Errorf("b finished after %v with exit code %d, want it played in full", b.Duration, b.ExitCode)
+	}
+}
@@ -0,0 +1,113 @@
+//go:build linux
+
+package goomx
+
+import (
+	"time"
+)
+
+// InterruptOptions configures PlayInterrupt.
+type InterruptOptions struct {
+	// Rewind resumes the interrupted clip this much before the position it
+	// was stopped at.
+	Rewind time.Duration
+	// NoResume continues with the playlist entry after the interrupted one
+	// instead of resuming it.
+	NoResume bool
+}
+
+// PlayInterrupt stops the playing clip and plays item, e.g. an urgent
+// announcement, then resumes the interrupted clip at the position it was
+// stopped at, with its remaining repetitions, and the playlist after it. An
+// interrupt requested while another is pending or playing plays after it,
+// without stopping it. A seek or a new playlist cancels the resume. It fails
+// with ErrNotRunning if playback is stopped.
+func (p *Player) PlayInterrupt(item PlaylistItem, opts InterruptOptions) error {
+	if err := item.validate(); err != nil {
+		return &PlayerError{Op: "interrupt", Path: item.Path, Err: err}
+	}
+	if !p.enablePlay.Get() {
+		return &PlayerError{Op: "interrupt", Path: item.Path, Err: ErrNotRunning}
+	}
+	var resume []FilePlay
+	if !opts.NoResume {
+		resume = p.resumePoint(opts.Rewind)
+	}
+	p.queueMu.Lock()
+	p.interrupts = append(p.interrupts, p.itemFilePlay(item))
+	if p.interrupting {
+		p.queueMu.Unlock()
+		return nil
+	}
+	p.interrupting = true
+	if !opts.NoResume {
+		p.resume = append(resume, p.resume...)
+	} else {
+		p.resume = nil
+	}
+	p.queueMu.Unlock()
+	p.gaplessMu.Lock()
+	discard := p.preloaded
+	p.preloaded = nil
+	p.gaplessMu.Unlock()
+	if discard != nil { // it would be the entry after the interrupted one
+		discard.kill()
+	}
+	if p.IsRunning() {
+		p.condStop.SetThenSendBroadcast(true)
+	}
+	return nil
+}
+
+// resumePoint returns the entries that resume the playing clip: the rest of
+// it from its current position less rewind, then its remaining repetitions
+// from the start. A stream has no position to resume at and is restarted.
+func (p *Player) resumePoint(rewind time.Duration) []FilePlay {
+	cur, _ := p.nowPlaying()
+	if cur.pathFile == "" {
+		return nil
+	}
+	var resume []FilePlay
+	if cur.isStreamLink {
+		rest := cur
+		rest.start, rest.loops = 0, 0
+		resume = append(resume, rest)
+	} else if us, err := p.Position(); err == nil {
+		pos := time.Duration(us) * time.Microsecond
+		rest := cur
+		rest.start = max(pos-rewind, 0)
+		rest.loops = 0
+		played := pos - cur.start
+		if cur.maxPlay > 0 {
+			rest.maxPlay = cur.maxPlay - played + (pos - rest.start)
+		}
+		if cur.maxPlay <= 0 || played < cur.maxPlay {
+			resume = append(resume, rest)
+		}
+	}
+	if cur.loops > 0 {
+		again := cur
+		again.loops--
+		resume = append(resume, again)
+	}
+	return resume
+}
+
+// takeInterrupt returns the next pending interrupt or, after the last one,
+// the entries resuming the interrupted clip, or false if there is none.
+func (p *Player) takeInterrupt() (FilePlay, bool) {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	if len(p.interrupts) > 0 {
+		fp := p.interrupts[0]
+		p.interrupts = p.interrupts[1:]
+		return fp, true
+	}
+	p.interrupting = false
+	if len(p.resume) > 0 {
+		fp := p.resume[0]
+		p.resume = p.resume[1:]
+		return fp, true
+	}
+	return FilePlay{}, false
+}
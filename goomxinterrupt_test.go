@@ -0,0 +1,58 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// interrupted plays path, interrupts it once it has played for at least
+// played with a short notice, and returns the position it resumed at.
+func interrupted(t *testing.T, path string, played time.Duration) time.Duration {
+	t.Helper()
+	p := newPlayer(t, goomxtest.Script{Duration: time.Minute}, nil)
+	events := p.Events()
+	p.ConfigureNewPlaylist([]string{path})
+	p.Play()
+
+	collect(t, events, goomx.EventStarted, 1)
+	deadline := time.Now().Add(eventTimeout)
+	for {
+		if us, err := p.Position(); err == nil && time.Duration(us)*time.Microsecond >= played {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("clip does not play")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	notice := clips(t, "notice.mp4")[0]
+	if err := p.PlayInterrupt(goomx.PlaylistItem{Path: notice, MaxPlay: 200 * time.Millisecond}, goomx.InterruptOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	started := collect(t, events, goomx.EventStarted, 2)
+	if started[0].Path != notice || started[1].Path != path {
+		t.Fatalf("started %v, want the notice and then the interrupted clip", paths(started))
+	}
+	us, err := p.Position()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return time.Duration(us) * time.Microsecond
+}
+
+func TestInterruptResumes(t *testing.T) {
+	if pos := interrupted(t, clips(t, "a.mp4")[0], time.Second); pos < time.Second {
+		t.Errorf("resumed at %v, want at least 1s", pos)
+	}
+}
+
+func TestInterruptRestartsStream(t *testing.T) {
+	if pos := interrupted(t, "rtsp://camera/live", time.Second); pos >= time.Second {
+		t.Errorf("stream resumed at %v, want it restarted", pos)
+	}
+}
@@ -0,0 +1,240 @@
+//go:build linux
+
+package goomx
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/sonnt85/goring"
+	"github.com/sonnt85/gosutils/slogrus"
+)
+
+// PlaybackMode selects how the playlist moves on when an entry ends.
+type PlaybackMode int
+
+const (
+	ModeRepeatAll PlaybackMode = iota // play the playlist in order, over and over
+	ModeOnce                          // play the playlist in order once, then stop
+	ModeRepeatOne                     // play the current entry over and over
+	ModeShuffle                       // play in random order, every entry once before any repeats
+)
+
+func (m PlaybackMode) String() string {
+	switch m {
+	case ModeRepeatAll:
+		return "repeat_all"
+	case ModeOnce:
+		return "once"
+	case ModeRepeatOne:
+		return "repeat_one"
+	case ModeShuffle:
+		return "shuffle"
+	default:
+		return "unknown"
+	}
+}
+
+// errPlaylistEnded is returned by nextEntry in ModeOnce after the last entry.
+var errPlaylistEnded = errors.New("end of playlist")
+
+// SetPlaybackMode selects the playback mode, taking effect when the current
+// entry ends; the current entry is not restarted. Switching to ModeShuffle
+// starts a new random order. PlayNextVideo, PlayPrevVideo and SeekVideos move
+// through the playlist in order in every mode.
+func (p *Player) SetPlaybackMode(mode PlaybackMode) error {
+	if mode < ModeRepeatAll || mode > ModeShuffle {
+		return &PlayerError{Op: "playback mode", Err: fmt.Errorf("unknown playback mode %d", mode)}
+	}
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	if mode == ModeShuffle && p.mode != ModeShuffle {
+		p.shuffleBag = nil
+	}
+	p.mode = mode
+	return nil
+}
+
+// PlaybackMode returns the playback mode.
+func (p *Player) PlaybackMode() PlaybackMode {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	return p.mode
+}
+
+// SetShuffleSeed seeds the random order of ModeShuffle, so the same seed and
+// playlist give the same order, and starts a new order.
+func (p *Player) SetShuffleSeed(seed int64) {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	p.shuffleRand = rand.New(rand.NewSource(seed))
+	p.shuffleBag = nil
+}
+
+// newShuffleRand returns the random source for seed, or a time-seeded one if
+// seed is 0.
+func newShuffleRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// cursor returns the index of the current playlist entry and the playlist
+// length. The index is kept by the player, as EventLinkedList does not expose
+// its read position. queueMu must be held by the caller.
+func (p *Player) cursor() (r, n int) {
+	if n = p.Length(); n > 0 {
+		r = p.pos % n // entries may have been removed
+	}
+	return r, n
+}
+
+// seekEntry moves the playlist to entry i, counted from either end, and
+// returns it. queueMu must be held by the caller.
+func (p *Player) seekEntry(i int) (string, error) {
+	list, err := p.Copy()
+	if err != nil {
+		return "", err
+	}
+	n := len(list)
+	i = (i%n + n) % n
+	p.Seek(i - p.pos%n) // keep the embedded read position in step for Current
+	p.pos = i
+	return list[i], nil
+}
+
+// nextIndex returns the index of the entry to play after the current one, r,
+// of a playlist of n entries, according to the playback mode. queueMu must be
+// held by the caller.
+func (p *Player) nextIndex(r, n int) (int, error) {
+	if p.queueHold {
+		return r, nil
+	}
+	if p.scheduler != nil {
+		if p.mode == ModeOnce && p.played >= n { // a pass is as long as the playlist
+			return 0, errPlaylistEnded
+		}
+		if i := p.scheduledIndex(p.scheduler, r); i >= 0 {
+			return i, nil
+		}
+	}
+	switch p.mode {
+	case ModeRepeatOne:
+		return r, nil
+	case ModeShuffle:
+		return p.shuffleNext(r, n), nil
+	}
+	next := r + p.SeekStep.Get()
+	if p.mode == ModeOnce && (next < 0 || next >= n) {
+		return 0, errPlaylistEnded
+	}
+	return (next%n + n) % n, nil
+}
+
+// nextEntry moves the playlist to the entry to play after the current one and
+// returns it. In ModeOnce, after the last entry it fails with
+// errPlaylistEnded and leaves the playlist on the first entry, to be played
+// when playback is restarted. queueMu must be held by the caller.
+func (p *Player) nextEntry() (string, error) {
+	r, n := p.cursor()
+	if n == 0 {
+		return "", goring.ErrIsEmpty
+	}
+	i, err := p.nextIndex(r, n)
+	if errors.Is(err, errPlaylistEnded) {
+		first := 0
+		if p.SeekStep.Get() < 0 {
+			first = n - 1
+		}
+		p.seekEntry(first)
+		p.queueHold = true
+		p.played = 0
+		return "", err
+	}
+	p.queueHold = false
+	p.played++
+	if p.mode == ModeShuffle {
+		p.shuffleTake(i)
+	}
+	if p.scheduler != nil {
+		if entries := p.GetPlaylistItems(); i < len(entries) {
+			p.scheduler.Played(entries, i)
+		}
+	}
+	return p.seekEntry(i)
+}
+
+// shuffleNext returns the next index of the shuffle order, starting a new
+// order of the n entries when the last one is used up or the playlist length
+// changed. A new order does not start with r, the entry just played. queueMu
+// must be held by the caller.
+func (p *Player) shuffleNext(r, n int) int {
+	if p.shuffleLen != n {
+		p.shuffleBag = nil
+	}
+	if len(p.shuffleBag) == 0 {
+		if p.shuffleRand == nil {
+			p.shuffleRand = newShuffleRand(p.config.ShuffleSeed)
+		}
+		p.shuffleBag = p.shuffleRand.Perm(n)
+		p.shuffleLen = n
+		if n > 1 && p.shuffleBag[0] == r {
+			j := 1 + p.shuffleRand.Intn(n-1)
+			p.shuffleBag[0], p.shuffleBag[j] = p.shuffleBag[j], p.shuffleBag[0]
+		}
+	}
+	return p.shuffleBag[0]
+}
+
+// shuffleTake removes index i from the shuffle order, as it is being played.
+// queueMu must be held by the caller.
+func (p *Player) shuffleTake(i int) {
+	for j, v := range p.shuffleBag {
+		if v == i {
+			p.shuffleBag = append(p.shuffleBag[:j], p.shuffleBag[j+1:]...)
+			return
+		}
+	}
+}
+
+// takeNext waits until there is an entry to play after the current one, as
+// chosen by nextEntry, and returns it. In ModeOnce, after the last entry it
+// stops playback and waits for it to be restarted. It fails only if the
+// player is closed.
+func (p *Player) takeNext() (string, error) {
+	for {
+		p.queueMu.Lock()
+		next, err := p.nextEntry()
+		p.queueMu.Unlock()
+		if err == nil {
+			return next, nil
+		}
+		if errors.Is(err, errPlaylistEnded) {
+			p.endPlaylist()
+			p.enablePlay.TestThenWaitSignalIfNotMatch(true)
+		} else { // empty playlist, wait for entries
+			p.waitEntries()
+		}
+		if err := p.ctx.Err(); err != nil {
+			return "", err
+		}
+	}
+}
+
+// restartPlaylist makes the next entry played the first one of the playlist,
+// or a random one when shuffling. queueMu must be held by the caller.
+func (p *Player) restartPlaylist() {
+	p.queueHold = p.mode != ModeShuffle
+	p.shuffleBag = nil
+	p.played = 0
+}
+
+// endPlaylist stops playback at the end of the playlist in ModeOnce.
+func (p *Player) endPlaylist() {
+	slogrus.Print("End of playlist")
+	p.enablePlay.Set(false)
+	p.emit(PlayerEvent{Type: EventPlaylistEnded})
+}
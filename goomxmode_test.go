@@ -0,0 +1,83 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// modePlayer returns a player with short clips in the given playback mode.
+func modePlayer(t *testing.T, mode goomx.PlaybackMode, seed int64) *goomx.Player {
+	return newPlayer(t, goomxtest.Script{Duration: 150 * time.Millisecond}, func(cfg *goomx.PlayerConfig) {
+		cfg.PlaybackMode = mode
+		cfg.ShuffleSeed = seed
+	})
+}
+
+func TestModeOnce(t *testing.T) {
+	p := modePlayer(t, goomx.ModeOnce, 0)
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4"))
+	p.Play()
+
+	if got, want := paths(collect(t, events, goomx.EventStarted, 2)), []string{"a.mp4", "b.mp4"}; !equal(got, want) {
+		t.Errorf("started %v, want %v", got, want)
+	}
+	collect(t, events, goomx.EventPlaylistEnded, 1)
+	if p.PlayIsActive() {
+		t.Errorf("playback is active after the end of the playlist")
+	}
+
+	// playing again starts over from the first entry
+	p.Play()
+	if ev := collect(t, events, goomx.EventStarted, 1)[0]; paths([]goomx.PlayerEvent{ev})[0] != "a.mp4" {
+		t.Errorf("restarted with %s, want a.mp4", ev.Path)
+	}
+}
+
+func TestModeRepeatOne(t *testing.T) {
+	p := modePlayer(t, goomx.ModeRepeatOne, 0)
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4"))
+	p.Play()
+
+	if got, want := paths(collect(t, events, goomx.EventStarted, 3)), []string{"a.mp4", "a.mp4", "a.mp4"}; !equal(got, want) {
+		t.Errorf("started %v, want %v", got, want)
+	}
+}
+
+func TestModeShuffle(t *testing.T) {
+	p := modePlayer(t, goomx.ModeShuffle, 42)
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4", "c.mp4"))
+	p.Play()
+
+	started := paths(collect(t, events, goomx.EventStarted, 6))
+	for _, round := range [][]string{started[:3], started[3:]} {
+		sorted := slices.Sorted(slices.Values(round))
+		if want := []string{"a.mp4", "b.mp4", "c.mp4"}; !equal(sorted, want) {
+			t.Errorf("shuffled round %v is not a permutation of %v", round, want)
+		}
+	}
+	if started[2] == started[3] {
+		t.Errorf("%s played twice in a row across rounds: %v", started[2], started)
+	}
+}
+
+func TestSetPlaybackMode(t *testing.T) {
+	p := modePlayer(t, goomx.ModeRepeatAll, 0)
+	if err := p.SetPlaybackMode(goomx.ModeShuffle + 1); err == nil {
+		t.Errorf("SetPlaybackMode accepted an unknown mode")
+	}
+	if err := p.SetPlaybackMode(goomx.ModeRepeatOne); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.PlaybackMode(); got != goomx.ModeRepeatOne {
+		t.Errorf("PlaybackMode() = %s, want %s", got, goomx.ModeRepeatOne)
+	}
+}
@@ -0,0 +1,306 @@
+//go:build linux
+
+package goomx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+const exeMpv = "mpv"
+
+var mpvSocketSeq atomic.Int64
+
+// MpvBackend plays playlist entries with mpv, controlled over its JSON IPC
+// socket (https://mpv.io/manual/stable/#json-ipc).
+type MpvBackend struct {
+	// Binary is the mpv executable, "mpv" if empty.
+	Binary string
+	// Args are passed to mpv before the player's args. If nil, mpv plays
+	// fullscreen without a terminal or on-screen controller.
+	Args []string
+}
+
+func (b *MpvBackend) Name() string { return "mpv" }
+
+func (b *MpvBackend) Start(ctx context.Context, file string, args, env []string) (Session, error) {
+	binary := b.Binary
+	if binary == "" {
+		binary = exeMpv
+	}
+	pre := b.Args
+	if pre == nil {
+		pre = []string{"--fullscreen", "--no-terminal", "--no-osc", "--idle=no"}
+	}
+	sock := filepath.Join(os.TempDir(), fmt.Sprintf("goomx-mpv-%d-%d.sock", os.Getpid(), mpvSocketSeq.Add(1)))
+	os.Remove(sock)
+	cmdArgs := make([]string, 0, len(pre)+len(args)+3)
+	cmdArgs = append(cmdArgs, pre...)
+	cmdArgs = append(cmdArgs, "--input-ipc-server="+sock)
+	cmdArgs = append(cmdArgs, args...)
+	cmdArgs = append(cmdArgs, "--", file)
+
+	s := &mpvSession{
+		cmd:     exec.Command(binary, cmdArgs...),
+		sock:    sock,
+		pending: make(map[int64]chan mpvReply),
+		exited:  make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	s.cmd.Env = env
+	s.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := s.cmd.Start(); err != nil {
+		return nil, startError(file, err)
+	}
+	go func() {
+		s.waitErr = s.cmd.Wait()
+		close(s.exited)
+	}()
+
+	connectCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.exited: // mpv gave up, e.g. on an unplayable file
+			cancel()
+		case <-connectCtx.Done():
+		}
+	}()
+	conn, err := waitUnixSocket(connectCtx, sock)
+	if err != nil {
+		s.Kill()
+		<-s.exited
+		os.Remove(sock)
+		var ee *exec.ExitError
+		if errors.As(s.waitErr, &ee) {
+			err = newExitError(file, ee.ProcessState)
+		}
+		return nil, &PlayerError{Op: "mpv connect", Path: file, Err: err}
+	}
+	s.conn = conn
+	go s.readReplies()
+	return s, nil
+}
+
+// mpvReply is a reply, or an event, read from the mpv IPC socket.
+type mpvReply struct {
+	Error     string          `json:"error"`
+	Data      json.RawMessage `json:"data"`
+	RequestID int64           `json:"request_id"`
+	Event     string          `json:"event"`
+}
+
+// mpvTrack is an entry of mpv's track-list property.
+type mpvTrack struct {
+	ID       int    `json:"id"`
+	Type     string `json:"type"`
+	Lang     string `json:"lang"`
+	Title    string `json:"title"`
+	Codec    string `json:"codec"`
+	Selected bool   `json:"selected"`
+}
+
+// mpvSession is the Session of an mpv process.
+type mpvSession struct {
+	cmd     *exec.Cmd
+	sock    string
+	conn    net.Conn
+	exited  chan struct{} // closed once cmd has been waited for
+	waitErr error
+	closed  chan struct{} // closed once the socket stops delivering replies
+
+	mu      sync.Mutex // guards pending, nextID and writes to conn
+	pending map[int64]chan mpvReply
+	nextID  int64
+}
+
+// readReplies dispatches replies to the commands waiting for them until the
+// socket is closed.
+func (s *mpvSession) readReplies() {
+	defer close(s.closed)
+	scanner := bufio.NewScanner(s.conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r mpvReply
+		if json.Unmarshal(scanner.Bytes(), &r) != nil || r.Event != "" {
+			continue
+		}
+		s.mu.Lock()
+		ch := s.pending[r.RequestID]
+		delete(s.pending, r.RequestID)
+		s.mu.Unlock()
+		if ch != nil {
+			ch <- r
+		}
+	}
+}
+
+// command runs an mpv IPC command and returns the data of its reply.
+func (s *mpvSession) command(ctx context.Context, args ...interface{}) (json.RawMessage, error) {
+	op := fmt.Sprint("mpv ", args[0])
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dbusCallTimeout)
+		defer cancel()
+	}
+	ch := make(chan mpvReply, 1)
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	line, err := json.Marshal(map[string]interface{}{"command": args, "request_id": id})
+	if err == nil {
+		s.pending[id] = ch
+		_, err = s.conn.Write(append(line, '\n'))
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+	if err != nil {
+		return nil, &PlayerError{Op: op, Err: fmt.Errorf("%w: %v", ErrNotRunning, err)}
+	}
+	select {
+	case r := <-ch:
+		if r.Error != "success" {
+			return nil, &PlayerError{Op: op, Err: errors.New(r.Error)}
+		}
+		return r.Data, nil
+	case <-s.closed:
+		return nil, &PlayerError{Op: op, Err: ErrNotRunning}
+	case <-ctx.Done():
+		return nil, &PlayerError{Op: op, Err: ctx.Err()}
+	}
+}
+
+// mpvProperty reads property name, which must unmarshal into T.
+func mpvProperty[T any](ctx context.Context, s *mpvSession, name string) (T, error) {
+	var v T
+	data, err := s.command(ctx, "get_property", name)
+	if err != nil {
+		return v, err
+	}
+	if err = json.Unmarshal(data, &v); err != nil {
+		return v, &PlayerError{Op: "mpv get_property " + name, Err: fmt.Errorf("%w: %v", ErrUnexpectedReply, err)}
+	}
+	return v, nil
+}
+
+func (s *mpvSession) setProperty(ctx context.Context, name string, value interface{}) error {
+	_, err := s.command(ctx, "set_property", name, value)
+	return err
+}
+
+func (s *mpvSession) Wait() error {
+	<-s.exited
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	os.Remove(s.sock)
+	return s.waitErr
+}
+
+func (s *mpvSession) Kill() error {
+	if s.cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-s.cmd.Process.Pid, syscall.SIGKILL)
+}
+
+func (s *mpvSession) Quit(ctx context.Context) error {
+	_, err := s.command(ctx, "quit")
+	return err
+}
+
+func (s *mpvSession) Pause(ctx context.Context) error { return s.setProperty(ctx, "pause", true) }
+func (s *mpvSession) Play(ctx context.Context) error  { return s.setProperty(ctx, "pause", false) }
+
+func (s *mpvSession) PlayPause(ctx context.Context) error {
+	_, err := s.command(ctx, "cycle", "pause")
+	return err
+}
+
+func (s *mpvSession) PlaybackStatus(ctx context.Context) (string, error) {
+	paused, err := mpvProperty[bool](ctx, s, "pause")
+	if err != nil {
+		return "", err
+	}
+	if paused {
+		return "Paused", nil
+	}
+	return "Playing", nil
+}
+
+func (s *mpvSession) Seek(ctx context.Context, offset int64) (int64, error) {
+	if _, err := s.command(ctx, "seek", float64(offset)/1e6, "relative"); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+func (s *mpvSession) SetPosition(ctx context.Context, path string, position int64) (int64, error) {
+	if err := s.setProperty(ctx, "time-pos", float64(position)/1e6); err != nil {
+		return 0, err
+	}
+	return position, nil
+}
+
+func (s *mpvSession) Volume(ctx context.Context, volume ...float64) (float64, error) {
+	if len(volume) != 0 {
+		if err := s.setProperty(ctx, "volume", volume[0]*100); err != nil {
+			return 0, err
+		}
+	}
+	v, err := mpvProperty[float64](ctx, s, "volume")
+	return v / 100, err
+}
+
+func (s *mpvSession) Position(ctx context.Context) (int64, error) {
+	pos, err := mpvProperty[float64](ctx, s, "time-pos")
+	return int64(pos * 1e6), err
+}
+
+func (s *mpvSession) Duration(ctx context.Context) (int64, error) {
+	dur, err := mpvProperty[float64](ctx, s, "duration")
+	return int64(dur * 1e6), err
+}
+
+func (s *mpvSession) ListAudio(ctx context.Context) ([]string, error) {
+	return s.tracks(ctx, "audio")
+}
+
+func (s *mpvSession) ListSubtitles(ctx context.Context) ([]string, error) {
+	return s.tracks(ctx, "sub")
+}
+
+// tracks lists the tracks of type typ in omxplayer's
+// "index:language:name:codec:active" format.
+func (s *mpvSession) tracks(ctx context.Context, typ string) ([]string, error) {
+	all, err := mpvProperty[[]mpvTrack](ctx, s, "track-list")
+	if err != nil {
+		return nil, err
+	}
+	list := make([]string, 0, len(all))
+	for _, t := range all {
+		if t.Type != typ {
+			continue
+		}
+		active := ""
+		if t.Selected {
+			active = "active"
+		}
+		list = append(list, fmt.Sprintf("%d:%s:%s:%s:%s", len(list), t.Lang, t.Title, t.Codec, active))
+	}
+	return list, nil
+}
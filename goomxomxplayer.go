@@ -0,0 +1,191 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	dbus "github.com/godbus/dbus"
+	"github.com/sonnt85/gosutils/slogrus"
+)
+
+// omxBackend is the Backend of omxplayer, which the player drives over D-Bus
+// itself. It is the only backend that preloads clips in gapless mode.
+type omxBackend struct {
+	p *Player
+}
+
+func (b *omxBackend) Name() string { return exeOxmPlayer }
+
+// Start plays file as startClip does, in the environment of the player.
+func (b *omxBackend) Start(ctx context.Context, file string, args, env []string) (Session, error) {
+	return b.startClip(ctx, FilePlay{pathFile: file, location: playLocation(file), isStreamLink: b.p.isStreamURL(file)}, args)
+}
+
+// startClip plays file with args, using the process preloaded for it in
+// gapless mode if there is one, and returns once the process owns its D-Bus
+// name or ctx is done. A new process starts at the start position of file; a
+// preloaded one is raised to the playing layer and unpaused.
+func (b *omxBackend) startClip(ctx context.Context, file FilePlay, args []string) (Session, error) {
+	p := b.p
+	gapless, _, layer := p.gaplessSettings()
+	s := &omxSession{name: p.dbusName, exited: make(chan struct{})}
+	pre := p.takePreloaded(file.pathFile)
+	var wait func() error
+	if pre != nil {
+		pre.cancel() // the session watches the name from now on
+		s.cmd, s.keys, s.conn, s.name = pre.cmd, pre.keys, pre.conn, pre.name
+		wait = pre.wait // the process is already waited for
+	} else {
+		if !gapless {
+			layer = -1
+		}
+		cmd, keys, err := execOmxplayer(p.config.OmxplayerBinary, p.processEnv(), file.location, p.omxArgs(file, s.name, layer, args)...)
+		if err != nil {
+			return nil, err
+		}
+		s.cmd, s.keys = cmd, keys
+		wait = cmd.Wait
+		if err = p.waitDbusFiles(); err != nil {
+			s.abort()
+			return nil, &PlayerError{Op: "dbus setup", Path: file.pathFile, Err: err}
+		}
+		if s.conn, err = p.getDbusConnection(); err != nil {
+			s.abort()
+			return nil, &PlayerError{Op: "dbus connect", Path: file.pathFile, Err: err}
+		}
+	}
+	s.bus = s.conn.Object(s.name, pathMpris)
+
+	watchCtx, cancel := context.WithCancel(p.ctx)
+	s.cancel = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		watchBusName(watchCtx, s.conn, s.name, p.ready)
+	}()
+	go func() {
+		s.waitErr = wait()
+		s.cancel()
+		s.wg.Wait() // the next process must not be marked not ready by this watcher
+		s.conn.Close()
+		close(s.exited)
+	}()
+
+	select {
+	case <-p.ready.wait(true):
+	case <-s.exited: // reported as the end of playback
+		return s, nil
+	case <-ctx.Done():
+		if p.ctx.Err() != nil {
+			s.Kill()
+			s.Wait()
+			return nil, &PlayerError{Op: "start", Path: file.pathFile, Err: ErrClosed}
+		}
+		slogrus.Print("omxplayer is not ready, playing on: ", file.pathFile)
+		return s, nil
+	}
+	if pre != nil {
+		if err := s.raise(ctx, layer); err != nil {
+			slogrus.Error("Can not raise preloaded clip", err)
+		}
+	}
+	p.learnURISchemes(ctx, s)
+	if gapless {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			p.preloadNext(watchCtx, s, file)
+		}()
+	}
+	return s, nil
+}
+
+// omxSession is the Session of an omxplayer process, controlled over D-Bus.
+type omxSession struct {
+	cmd     *exec.Cmd
+	keys    io.WriteCloser // standard input of cmd
+	conn    *dbus.Conn
+	bus     dbus.BusObject
+	name    string             // D-Bus name
+	cancel  context.CancelFunc // stops the goroutines of wg
+	wg      sync.WaitGroup     // name watcher and gapless preloader
+	exited  chan struct{}      // closed once cmd has exited and wg is done
+	waitErr error
+}
+
+// abort kills a process that could not be connected to and waits for it.
+func (s *omxSession) abort() {
+	s.Kill()
+	s.cmd.Wait()
+}
+
+func (s *omxSession) Wait() error {
+	<-s.exited
+	return s.waitErr
+}
+
+func (s *omxSession) Kill() error {
+	if s.cmd.Process == nil {
+		return nil
+	}
+	io.WriteString(s.keys, keyQuit)
+	return syscall.Kill(-s.cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// raise moves a preloaded process to the playing layer and unpauses it.
+func (s *omxSession) raise(ctx context.Context, layer int) error {
+	if err := s.call(ctx, cmdSetLayer, int64(layer)); err != nil {
+		return err
+	}
+	return s.call(ctx, cmdAction, int32(ACTION_PLAY))
+}
+
+func (s *omxSession) call(ctx context.Context, method string, args ...interface{}) error {
+	_, err := dbusDoOn(ctx, s.bus, method, args...)
+	return err
+}
+
+func (s *omxSession) Quit(ctx context.Context) error      { return s.call(ctx, cmdQuit) }
+func (s *omxSession) Pause(ctx context.Context) error     { return s.call(ctx, cmdPause) }
+func (s *omxSession) Play(ctx context.Context) error      { return s.call(ctx, cmdPlay) }
+func (s *omxSession) PlayPause(ctx context.Context) error { return s.call(ctx, cmdPlayPause) }
+
+func (s *omxSession) PlaybackStatus(ctx context.Context) (string, error) {
+	return dbusValueOn[string](ctx, s.bus, propPlaybackStatus)
+}
+
+func (s *omxSession) Seek(ctx context.Context, offset int64) (int64, error) {
+	return dbusValueOn[int64](ctx, s.bus, cmdSeek, offset)
+}
+
+func (s *omxSession) SetPosition(ctx context.Context, path string, position int64) (int64, error) {
+	return dbusValueOn[int64](ctx, s.bus, cmdSetPosition, dbus.ObjectPath(path), position)
+}
+
+func (s *omxSession) Volume(ctx context.Context, volume ...float64) (float64, error) {
+	if len(volume) == 0 {
+		return dbusValueOn[float64](ctx, s.bus, cmdVolume)
+	}
+	return dbusValueOn[float64](ctx, s.bus, cmdVolume, volume[0])
+}
+
+func (s *omxSession) Position(ctx context.Context) (int64, error) {
+	return dbusValueOn[int64](ctx, s.bus, propPosition)
+}
+
+func (s *omxSession) Duration(ctx context.Context) (int64, error) {
+	return dbusValueOn[int64](ctx, s.bus, propDuration)
+}
+
+func (s *omxSession) ListAudio(ctx context.Context) ([]string, error) {
+	return dbusValueOn[[]string](ctx, s.bus, cmdListAudio)
+}
+
+func (s *omxSession) ListSubtitles(ctx context.Context) ([]string, error) {
+	return dbusValueOn[[]string](ctx, s.bus, cmdListSubtitles)
+}
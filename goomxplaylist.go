@@ -0,0 +1,201 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PlaylistItem is a playlist entry with its playback settings. Settings are
+// kept per path, so an entry listed twice plays with the same settings both
+// times.
+type PlaylistItem struct {
+	Path string
+	// Title and Duration describe the entry, as read from or written to a
+	// playlist file. Duration does not limit playback; see MaxPlay.
+	Title    string
+	Duration time.Duration
+	// Stream marks Path as a network stream rather than a local file. URLs
+	// with a known network scheme are streams without it.
+	Stream bool
+	// Args are passed to the player after the player's own args.
+	Args []string
+	// Start is the position playback starts at.
+	Start time.Duration
+	// MaxPlay stops the item after it has played this long. 0 plays it to
+	// the end.
+	MaxPlay time.Duration
+	// Volume is the linear volume the item plays at, 1.0 for 100%. 0 keeps
+	// the player's volume.
+	Volume float64
+	// Loops is how many more times the item plays in a row before the
+	// playlist moves on. Moving through the playlist, Stop and a new
+	// playlist end the repetitions.
+	Loops int
+	// Weight and Share set how often a WeightedScheduler plays the item:
+	// Share is a percentage of all plays, and the plays left are split
+	// between items without a Share in proportion to their Weight, 1 if 0.
+	Weight float64
+	Share  float64
+	// Tag groups items a WeightedScheduler keeps apart, e.g. by advertiser.
+	Tag string
+}
+
+// validate checks the settings of item.
+func (item *PlaylistItem) validate() error {
+	switch {
+	case item.Path == "":
+		return errors.New("playlist item has no path")
+	case item.Start < 0, item.MaxPlay < 0, item.Volume < 0, item.Loops < 0, item.Weight < 0, item.Share < 0:
+		return errors.New("playlist item has a negative setting")
+	case item.Share > 100:
+		return errors.New("playlist item has a share over 100%")
+	}
+	return nil
+}
+
+// setItems records the settings of items, replacing those of every path not
+// kept, which are forgotten. Invalid items are rejected as a whole.
+func (p *Player) setItems(items []PlaylistItem, keep func(path string) bool) error {
+	for i := range items {
+		if err := items[i].validate(); err != nil {
+			return &PlayerError{Op: "playlist", Path: items[i].Path, Err: err}
+		}
+	}
+	p.itemsMu.Lock()
+	defer p.itemsMu.Unlock()
+	for path := range p.items {
+		if keep == nil || !keep(path) {
+			delete(p.items, path)
+		}
+	}
+	if p.items == nil {
+		p.items = make(map[string]PlaylistItem, len(items))
+	}
+	for _, item := range items {
+		item.Args = append([]string(nil), item.Args...)
+		p.items[item.Path] = item
+	}
+	return nil
+}
+
+// pruneItems forgets the settings of every path not in list.
+func (p *Player) pruneItems(list []string) {
+	listed := make(map[string]bool, len(list))
+	for _, path := range list {
+		listed[path] = true
+	}
+	p.setItems(nil, func(path string) bool { return listed[path] })
+}
+
+// AddItem inserts item at index, from 0 to the playlist length, and records
+// its settings. Nothing changes if item is invalid.
+func (p *Player) AddItem(item PlaylistItem, index int) error {
+	if err := item.validate(); err != nil {
+		return &PlayerError{Op: "playlist", Path: item.Path, Err: err}
+	}
+	if err := p.insertEntry(item.Path, index); err != nil {
+		return err
+	}
+	return p.setItems([]PlaylistItem{item}, func(string) bool { return true })
+}
+
+// ConfigureNewPlaylistItems replaces the playlist with items, as
+// ConfigureNewPlaylist does, and their settings. It reports whether the list
+// of paths changed; new settings for the playing entry apply the next time it
+// is started. Nothing changes if an item is invalid.
+func (p *Player) ConfigureNewPlaylistItems(items []PlaylistItem) (changed bool, err error) {
+	return p.configureNewPlaylistItems(items, true)
+}
+
+func (p *Player) configureNewPlaylistItems(items []PlaylistItem, interrupt bool) (changed bool, err error) {
+	if err = p.setItems(items, nil); err != nil {
+		return false, err
+	}
+	list := make([]string, len(items))
+	for i, item := range items {
+		list[i] = item.Path
+	}
+	return p.configureNewPlaylist(list, interrupt, nil), nil
+}
+
+// Item returns the settings of the playlist entry path, or false if it has
+// none.
+func (p *Player) Item(path string) (PlaylistItem, bool) {
+	p.itemsMu.Lock()
+	defer p.itemsMu.Unlock()
+	item, ok := p.items[path]
+	if ok {
+		item.Args = append([]string(nil), item.Args...)
+	}
+	return item, ok
+}
+
+// GetPlaylistItems returns the playlist with the settings of each entry.
+// Entries without settings have only their Path set.
+func (p *Player) GetPlaylistItems() []PlaylistItem {
+	list := p.GetPlaylist()
+	items := make([]PlaylistItem, len(list))
+	for i, path := range list {
+		if item, ok := p.Item(path); ok {
+			items[i] = item
+		} else {
+			items[i] = PlaylistItem{Path: path}
+		}
+	}
+	return items
+}
+
+// filePlay returns the launch settings for the playlist entry path.
+func (p *Player) filePlay(path string) FilePlay {
+	item, ok := p.Item(path)
+	if !ok {
+		item = PlaylistItem{Path: path}
+	}
+	return p.itemFilePlay(item)
+}
+
+// itemFilePlay returns the launch settings for item.
+func (p *Player) itemFilePlay(item PlaylistItem) FilePlay {
+	return FilePlay{
+		pathFile:     item.Path,
+		location:     playLocation(item.Path),
+		isStreamLink: item.Stream || p.isStreamURL(item.Path),
+		args:         item.Args,
+		start:        item.Start,
+		maxPlay:      item.MaxPlay,
+		volume:       item.Volume,
+		loops:        item.Loops,
+	}
+}
+
+// applyVolume sets the volume of session s, playing file, to the file's own
+// volume or else the player's saved one. The saved volume is not changed and
+// no EventVolumeChanged is emitted.
+func (p *Player) applyVolume(ctx context.Context, s Session, file FilePlay) error {
+	volume := file.volume
+	if volume <= 0 {
+		volume = p.GetSavedVolume()
+	}
+	_, err := s.Volume(ctx, volume)
+	return err
+}
+
+// playLimit returns a channel that fires once file has played for its
+// maximum play time, or nil if it has none, and a function releasing it.
+func playLimit(file FilePlay) (<-chan time.Time, func() bool) {
+	if file.maxPlay <= 0 {
+		return nil, func() bool { return false }
+	}
+	t := time.NewTimer(file.maxPlay)
+	return t.C, t.Stop
+}
+
+// formatPosition formats d as omxplayer's --pos argument, hh:mm:ss.
+func formatPosition(d time.Duration) string {
+	s := int64(d / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
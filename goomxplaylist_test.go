@@ -0,0 +1,158 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// calls returns the method calls the fake received from p, without the D-Bus
+// name.
+func calls(t *testing.T, p *goomx.Player) []string {
+	t.Helper()
+	all, err := harness.Calls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var own []string
+	for _, c := range all {
+		if rest, ok := strings.CutPrefix(c, p.DbusName()+" "); ok {
+			own = append(own, rest)
+		}
+	}
+	return own
+}
+
+func TestItemSettings(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: 10 * time.Second}, nil)
+	events := p.Events()
+	list := clips(t, "a.mp4", "b.mp4")
+	p.ConfigureNewPlaylistItems([]goomx.PlaylistItem{
+		{Path: list[0], Volume: 0.5, Start: 2 * time.Second, MaxPlay: time.Second, Loops: 1},
+		{Path: list[1], MaxPlay: 200 * time.Millisecond},
+	})
+	p.Play()
+
+	collect(t, events, goomx.EventStarted, 1)
+	if pos, err := p.Position(); err != nil || pos < (2*time.Second).Microseconds() {
+		t.Errorf("Position() = %d, %v, want at least the start position 2s", pos, err)
+	}
+	finished := collect(t, events, goomx.EventFinished, 3)
+	if got, want := paths(finished), []string{"a.mp4", "a.mp4", "b.mp4"}; !equal(got, want) {
+		t.Errorf("finished %v, want %v", got, want)
+	}
+	for _, ev := range finished {
+		if ev.Duration > 5*time.Second {
+			t.Errorf("%s played %v, beyond its maximum play time", ev.Path, ev.Duration)
+		}
+	}
+	own := calls(t, p)
+	if !slices.Contains(own, "org.freedesktop.DBus.Properties.Volume 0.5") {
+		t.Errorf("item volume not set, calls %v", own)
+	}
+	if !slices.Contains(own, "org.freedesktop.DBus.Properties.Volume 0.03") {
+		t.Errorf("saved volume not restored for an item without one, calls %v", own)
+	}
+	if got := p.GetSavedVolume(); got != 0.03 {
+		t.Errorf("saved volume changed to %g by an item", got)
+	}
+}
+
+func TestItemSettingsKept(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	list := clips(t, "a.mp4", "b.mp4")
+	if changed, err := p.ConfigureNewPlaylistItems([]goomx.PlaylistItem{{Path: list[0], Title: "A", Loops: 2}, {Path: list[1], Title: "B"}}); err != nil || !changed {
+		t.Fatalf("ConfigureNewPlaylistItems = %t, %v", changed, err)
+	}
+
+	p.ConfigureNewPlaylist(list[:1])
+	if item, ok := p.Item(list[0]); !ok || item.Title != "A" || item.Loops != 2 {
+		t.Errorf("Item(a) = %+v, %t, want its settings kept", item, ok)
+	}
+	if _, ok := p.Item(list[1]); ok {
+		t.Errorf("settings of b kept after it left the playlist")
+	}
+	items := p.GetPlaylistItems()
+	if len(items) != 1 || items[0].Title != "A" {
+		t.Errorf("GetPlaylistItems() = %+v", items)
+	}
+}
+
+func TestItemSettingsInvalid(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	list := clips(t, "a.mp4", "b.mp4")
+	p.ConfigureNewPlaylist(list[:1])
+
+	for _, item := range []goomx.PlaylistItem{
+		{},
+		{Path: list[1], Volume: -1},
+		{Path: list[1], Share: 101},
+	} {
+		if changed, err := p.ConfigureNewPlaylistItems([]goomx.PlaylistItem{{Path: list[0]}, item}); err == nil || changed {
+			t.Errorf("invalid item %+v accepted: %t, %v", item, changed, err)
+		}
+		if err := p.AddItem(item, 0); err == nil {
+			t.Errorf("AddItem accepted invalid item %+v", item)
+		}
+	}
+	if got := p.GetPlaylist(); len(got) != 1 || filepath.Base(got[0]) != "a.mp4" {
+		t.Errorf("playlist changed to %v by invalid items", got)
+	}
+}
+
+func TestAddItemOrder(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	list := clips(t, "a.mp4", "b.mp4", "c.mp4", "d.mp4", "e.mp4")
+	for _, step := range []struct {
+		path  string
+		index int
+		want  []string
+	}{
+		{list[2], 0, []string{"c.mp4"}}, // into an empty playlist
+		{list[0], 0, []string{"a.mp4", "c.mp4"}},
+		{list[1], 1, []string{"a.mp4", "b.mp4", "c.mp4"}},
+		{list[4], 3, []string{"a.mp4", "b.mp4", "c.mp4", "e.mp4"}},
+		{list[3], 3, []string{"a.mp4", "b.mp4", "c.mp4", "d.mp4", "e.mp4"}},
+	} {
+		if err := p.AddItem(goomx.PlaylistItem{Path: step.path, Title: filepath.Base(step.path)}, step.index); err != nil {
+			t.Fatalf("AddItem(%s, %d): %v", filepath.Base(step.path), step.index, err)
+		}
+		if got := p.GetPlaylistWithoutPath(); !equal(got, step.want) {
+			t.Fatalf("after inserting %s at %d the playlist is %v, want %v", filepath.Base(step.path), step.index, got, step.want)
+		}
+	}
+	if item, ok := p.Item(list[3]); !ok || item.Title != "d.mp4" {
+		t.Errorf("Item(d) = %+v, %t, want its settings recorded", item, ok)
+	}
+	for _, index := range []int{-1, 6} {
+		if err := p.AddItem(goomx.PlaylistItem{Path: list[0]}, index); err == nil {
+			t.Errorf("AddItem at %d accepted", index)
+		}
+	}
+}
+
+// TestAddItemKeepsCursor inserts before the playing entry, which must not
+// make the player repeat it.
+func TestAddItemKeepsCursor(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: 300 * time.Millisecond}, nil)
+	events := p.Events()
+	list := clips(t, "a.mp4", "b.mp4", "c.mp4")
+	p.ConfigureNewPlaylist(list[:2])
+	p.Play()
+
+	collect(t, events, goomx.EventStarted, 1)
+	if err := p.AddItem(goomx.PlaylistItem{Path: list[2]}, 0); err != nil {
+		t.Fatal(err)
+	}
+	started := collect(t, events, goomx.EventStarted, 2)
+	if got, want := paths(started), []string{"b.mp4", "c.mp4"}; !equal(got, want) {
+		t.Errorf("started %v after the insert, want %v", got, want)
+	}
+}
@@ -0,0 +1,281 @@
+//go:build linux
+
+package goomx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// playlistFormat is a playlist file format.
+type playlistFormat struct {
+	parse func(r io.Reader, dir string) ([]PlaylistItem, error)
+	write func(w io.Writer, items []PlaylistItem) error
+}
+
+// playlistFormats are the playlist file formats by file extension.
+var playlistFormats = map[string]playlistFormat{
+	".m3u":  {ParseM3U, WriteM3U},
+	".m3u8": {ParseM3U, WriteM3U},
+	".pls":  {ParsePLS, WritePLS},
+	".xspf": {ParseXSPF, WriteXSPF},
+}
+
+// ReadPlaylistFile reads the playlist file name, in the format given by its
+// extension (.m3u, .m3u8, .pls or .xspf). Relative entries are resolved against the
+// directory of name.
+func ReadPlaylistFile(name string) ([]PlaylistItem, error) {
+	format, ok := playlistFormats[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return nil, &PlayerError{Op: "read playlist", Path: name, Err: fmt.Errorf("%w: unknown extension", ErrPlaylistFormat)}
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, &PlayerError{Op: "read playlist", Path: name, Err: err}
+	}
+	defer f.Close()
+	dir, err := filepath.Abs(filepath.Dir(name))
+	if err != nil {
+		return nil, &PlayerError{Op: "read playlist", Path: name, Err: err}
+	}
+	items, err := format.parse(f, dir)
+	if err != nil {
+		return nil, &PlayerError{Op: "read playlist", Path: name, Err: err}
+	}
+	return items, nil
+}
+
+// WritePlaylistFile writes items to the playlist file name, in the format
+// given by its extension.
+func WritePlaylistFile(name string, items []PlaylistItem) error {
+	format, ok := playlistFormats[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return &PlayerError{Op: "write playlist", Path: name, Err: fmt.Errorf("%w: unknown extension", ErrPlaylistFormat)}
+	}
+	var buf bytes.Buffer
+	if err := format.write(&buf, items); err != nil {
+		return &PlayerError{Op: "write playlist", Path: name, Err: err}
+	}
+	if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		return &PlayerError{Op: "write playlist", Path: name, Err: err}
+	}
+	return nil
+}
+
+// LoadPlaylistFile replaces the playlist with the entries of the playlist
+// file name, as ConfigureNewPlaylistItems does. Nothing changes if an entry
+// is invalid.
+func (p *Player) LoadPlaylistFile(name string) (changed bool, err error) {
+	items, err := ReadPlaylistFile(name)
+	if err != nil {
+		return false, err
+	}
+	return p.ConfigureNewPlaylistItems(items)
+}
+
+// SavePlaylistFile writes the playlist, with the titles and durations of its
+// entries, to the playlist file name. XSPF files also keep the other
+// settings of each entry.
+func (p *Player) SavePlaylistFile(name string) error {
+	return WritePlaylistFile(name, p.GetPlaylistItems())
+}
+
+// resolveLocation turns a playlist file entry into a playlist path: file
+// URLs become paths, other URLs are kept and relative paths are resolved
+// against dir.
+func resolveLocation(loc, dir string) string {
+	if u, err := url.Parse(loc); err == nil && strings.EqualFold(u.Scheme, "file") {
+		return u.Path
+	}
+	if urlScheme(loc) != "" || filepath.IsAbs(loc) {
+		return loc
+	}
+	return filepath.Join(dir, filepath.FromSlash(strings.ReplaceAll(loc, `\`, "/")))
+}
+
+// seconds converts a playlist duration in seconds to a time.Duration. A
+// negative or invalid value, meaning unknown, is 0.
+func seconds(s string) time.Duration {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	return time.Duration(v * float64(time.Second))
+}
+
+// lines returns the lines of r without surrounding space or a byte order
+// mark.
+func lines(r io.Reader) ([]string, error) {
+	var list []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(list) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		list = append(list, strings.TrimSpace(line))
+	}
+	return list, scanner.Err()
+}
+
+// ParseM3U parses an M3U or M3U8 playlist. #EXTINF lines set the Duration
+// and Title of the entry that follows; other comments are ignored. Relative
+// entries are resolved against dir.
+func ParseM3U(r io.Reader, dir string) ([]PlaylistItem, error) {
+	list, err := lines(r)
+	if err != nil {
+		return nil, err
+	}
+	var items []PlaylistItem
+	var info PlaylistItem
+	for _, line := range list {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			// #EXTINF:<seconds>[ key="value"...],<title>
+			info = PlaylistItem{}
+			attrs, title := cutExtinf(line[len("#EXTINF:"):])
+			if i := strings.IndexAny(attrs, " \t"); i >= 0 {
+				attrs = attrs[:i]
+			}
+			info.Duration = seconds(attrs)
+			info.Title = strings.TrimSpace(title)
+		case strings.HasPrefix(line, "#"):
+		default:
+			info.Path = resolveLocation(line, dir)
+			items = append(items, info)
+			info = PlaylistItem{}
+		}
+	}
+	return items, nil
+}
+
+// cutExtinf splits the value of an #EXTINF line at the comma ending its
+// attributes, skipping commas in quoted values such as tvg-name="a, b".
+func cutExtinf(s string) (attrs, title string) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				return s[:i], s[i+1:]
+			}
+		}
+	}
+	return s, ""
+}
+
+// WriteM3U writes items as an extended M3U playlist, in UTF-8.
+func WriteM3U(w io.Writer, items []PlaylistItem) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#EXTM3U\n")
+	for _, item := range items {
+		if item.Title != "" || item.Duration > 0 {
+			length := -1
+			if item.Duration > 0 {
+				length = int(item.Duration.Round(time.Second) / time.Second)
+			}
+			fmt.Fprintf(bw, "#EXTINF:%d,%s\n", length, item.Title)
+		}
+		bw.WriteString(item.Path + "\n")
+	}
+	return bw.Flush()
+}
+
+// ParsePLS parses a PLS playlist: File<n> entries with optional Title<n> and
+// Length<n>, in the order of n. Relative entries are resolved against dir.
+func ParsePLS(r io.Reader, dir string) ([]PlaylistItem, error) {
+	list, err := lines(r)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[int]*PlaylistItem)
+	seen := false       // a [playlist] section was found
+	inPlaylist := false // the lines are in it
+	for _, line := range list {
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inPlaylist = strings.EqualFold(line, "[playlist]")
+			seen = seen || inPlaylist
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || !inPlaylist {
+			continue
+		}
+		key, value = strings.TrimSpace(strings.ToLower(key)), strings.TrimSpace(value)
+		var field string
+		for _, f := range []string{"file", "title", "length"} {
+			if strings.HasPrefix(key, f) {
+				field = f
+				break
+			}
+		}
+		n, err := strconv.Atoi(key[len(field):])
+		if field == "" || err != nil {
+			continue // NumberOfEntries, Version
+		}
+		e := entries[n]
+		if e == nil {
+			e = &PlaylistItem{}
+			entries[n] = e
+		}
+		switch field {
+		case "file":
+			e.Path = resolveLocation(value, dir)
+		case "title":
+			e.Title = value
+		case "length":
+			e.Duration = seconds(value)
+		}
+	}
+	if !seen {
+		return nil, fmt.Errorf("%w: no [playlist] section", ErrPlaylistFormat)
+	}
+	keys := make([]int, 0, len(entries))
+	for n := range entries {
+		keys = append(keys, n)
+	}
+	sort.Ints(keys)
+	items := make([]PlaylistItem, 0, len(keys))
+	for _, n := range keys {
+		if entries[n].Path != "" {
+			items = append(items, *entries[n])
+		}
+	}
+	return items, nil
+}
+
+// WritePLS writes items as a version 2 PLS playlist.
+func WritePLS(w io.Writer, items []PlaylistItem) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[playlist]\n")
+	for i, item := range items {
+		n := i + 1
+		fmt.Fprintf(bw, "File%d=%s\n", n, item.Path)
+		if item.Title != "" {
+			fmt.Fprintf(bw, "Title%d=%s\n", n, item.Title)
+		}
+		length := -1
+		if item.Duration > 0 {
+			length = int(item.Duration.Round(time.Second) / time.Second)
+		}
+		fmt.Fprintf(bw, "Length%d=%d\n", n, length)
+	}
+	fmt.Fprintf(bw, "NumberOfEntries=%d\nVersion=2\n", len(items))
+	return bw.Flush()
+}
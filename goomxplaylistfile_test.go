@@ -0,0 +1,125 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// playlistItems returns items with every setting, to be written to playlist
+// files in dir.
+func playlistItems(dir string) []goomx.PlaylistItem {
+	return []goomx.PlaylistItem{
+		{Path: filepath.Join(dir, "a.mp4"), Title: "First, with a comma", Duration: 12 * time.Second},
+		{Path: filepath.Join(dir, "b c #1.mp4"), Duration: 3 * time.Second, Args: []string{"--no-osd"}, Start: 1500 * time.Millisecond,
+			MaxPlay: 2 * time.Second, Volume: 0.5, Loops: 2, Weight: 2, Share: 25, Tag: "ad"},
+		{Path: "rtsp://camera/stream", Title: "Camera", Stream: true},
+	}
+}
+
+// readBack writes items to a playlist file named name and reads it back.
+func readBack(t *testing.T, name string, items []goomx.PlaylistItem) []goomx.PlaylistItem {
+	t.Helper()
+	if err := goomx.WritePlaylistFile(name, items); err != nil {
+		t.Fatal(err)
+	}
+	got, err := goomx.ReadPlaylistFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestPlaylistFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	items := playlistItems(dir)
+	// M3U and PLS keep the path, title and duration of each entry
+	want := make([]goomx.PlaylistItem, len(items))
+	for i, item := range items {
+		want[i] = goomx.PlaylistItem{Path: item.Path, Title: item.Title, Duration: item.Duration}
+	}
+	for _, name := range []string{"list.m3u", "list.m3u8", "list.pls"} {
+		if got := readBack(t, filepath.Join(dir, name), items); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s read back\n%+v\nwant\n%+v", name, got, want)
+		}
+	}
+}
+
+func TestPlaylistFileUnknownExtension(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "list.txt")
+	if err := goomx.WritePlaylistFile(name, nil); !errors.Is(err, goomx.ErrPlaylistFormat) {
+		t.Errorf("WritePlaylistFile: %v, want ErrPlaylistFormat", err)
+	}
+	if _, err := goomx.ReadPlaylistFile(name); !errors.Is(err, goomx.ErrPlaylistFormat) {
+		t.Errorf("ReadPlaylistFile: %v, want ErrPlaylistFormat", err)
+	}
+}
+
+func TestParseM3U(t *testing.T) {
+	const list = "\ufeff#EXTM3U\n" +
+		"#EXTINF:5 tvg-name=\"News, live\" tvg-logo=\"x.png\",News\n" +
+		"http://example.com/news.m3u8\n" +
+		"\n" +
+		"# a comment\n" +
+		"clips/a.mp4\n" +
+		"file:///media/b.mp4\n"
+	got, err := goomx.ParseM3U(strings.NewReader(list), "/srv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []goomx.PlaylistItem{
+		{Path: "http://example.com/news.m3u8", Title: "News", Duration: 5 * time.Second},
+		{Path: "/srv/clips/a.mp4"},
+		{Path: "/media/b.mp4"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseM3U =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParsePLS(t *testing.T) {
+	const list = "[playlist]\n" +
+		"File2=b.mp4\n" +
+		"File1=a.mp4\n" +
+		"Title1=A\n" +
+		"Length1=-1\n" +
+		"NumberOfEntries=2\n" +
+		"[other]\n" +
+		"File3=c.mp4\n"
+	got, err := goomx.ParsePLS(strings.NewReader(list), "/srv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []goomx.PlaylistItem{{Path: "/srv/a.mp4", Title: "A"}, {Path: "/srv/b.mp4"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePLS =\n%+v\nwant\n%+v", got, want)
+	}
+	if _, err := goomx.ParsePLS(strings.NewReader("File1=a.mp4\n"), "/srv"); !errors.Is(err, goomx.ErrPlaylistFormat) {
+		t.Errorf("ParsePLS without [playlist]: %v, want ErrPlaylistFormat", err)
+	}
+}
+
+func TestLoadPlaylistFileInvalid(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	dir := t.TempDir()
+	list := clips(t, "a.mp4")
+	p.ConfigureNewPlaylist(list)
+	name := filepath.Join(dir, "list.xspf")
+	if err := goomx.WritePlaylistFile(name, []goomx.PlaylistItem{{Path: filepath.Join(dir, "b.mp4"), Share: 150}}); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := p.LoadPlaylistFile(name); err == nil || changed {
+		t.Errorf("LoadPlaylistFile = %t, %v, want the invalid share rejected", changed, err)
+	}
+	if got := p.GetPlaylist(); !equal(got, list) {
+		t.Errorf("playlist changed to %v by an invalid file", got)
+	}
+}
@@ -0,0 +1,335 @@
+//go:build linux
+
+package goomx
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sonnt85/gosutils/slogrus"
+)
+
+const (
+	ReasonCompleted   = "completed"   // clip played to the end
+	ReasonInterrupted = "interrupted" // stopped by the player (Stop, seek, new playlist, Close)
+	ReasonFailed      = "failed"      // omxplayer exited with an error or could not be controlled
+)
+
+// ProofOfPlayRecord is one line of the proof-of-play log.
+type ProofOfPlayRecord struct {
+	Player   string    `json:"player"`
+	File     string    `json:"file"`
+	Checksum string    `json:"sha256,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Reason   string    `json:"reason"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// ProofOfPlayRecorder appends a ProofOfPlayRecord per playback to a JSON-lines
+// file, rotating it to path.1 ... path.N once it would grow beyond maxSize.
+// Attach it to a player with Player.SetProofOfPlay.
+type ProofOfPlayRecorder struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+
+	sumMu     sync.Mutex
+	checksums map[string]fileChecksum
+}
+
+type fileChecksum struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// NewProofOfPlayRecorder opens (or creates) the log at path. A maxSize <= 0
+// disables rotation; maxBackups is the number of rotated files kept, 0
+// keeping them all.
+func NewProofOfPlayRecorder(path string, maxSize int64, maxBackups int) (*ProofOfPlayRecorder, error) {
+	r := &ProofOfPlayRecorder{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		checksums:  make(map[string]fileChecksum),
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *ProofOfPlayRecorder) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = fi.Size()
+	return nil
+}
+
+// Record appends rec to the log, filling in the file checksum if it is empty.
+// The file is hashed before the log is locked, so an export or another record
+// is not held up by it.
+func (r *ProofOfPlayRecorder) Record(rec ProofOfPlayRecord) error {
+	if rec.Checksum == "" {
+		rec.Checksum = r.checksum(rec.File)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(line)) > r.maxSize {
+		if err = r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+// rotate shifts path.N-1 to path.N, ..., path to path.1 and reopens path.
+// With maxBackups 0 every backup is shifted and none is removed.
+func (r *ProofOfPlayRecorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+	n := r.backups()
+	if r.maxBackups > 0 {
+		os.Remove(r.backupName(n))
+		n--
+	}
+	for i := n; i >= 1; i-- {
+		os.Rename(r.backupName(i), r.backupName(i+1))
+	}
+	if err := os.Rename(r.path, r.backupName(1)); err != nil {
+		// keep appending to the active log rather than losing it
+		if oerr := r.open(); oerr != nil {
+			return oerr
+		}
+		return err
+	}
+	return r.open()
+}
+
+// backups returns the number of backup slots: maxBackups or, if that is 0,
+// the number of consecutive backups on disk.
+func (r *ProofOfPlayRecorder) backups() int {
+	if r.maxBackups > 0 {
+		return r.maxBackups
+	}
+	n := 0
+	for {
+		if _, err := os.Stat(r.backupName(n + 1)); err != nil {
+			return n
+		}
+		n++
+	}
+}
+
+func (r *ProofOfPlayRecorder) backupName(i int) string {
+	return r.path + "." + strconv.Itoa(i)
+}
+
+// checksum returns the hex SHA-256 of a local file, cached by size and
+// modification time. Streams and unreadable files yield "".
+func (r *ProofOfPlayRecorder) checksum(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return ""
+	}
+	r.sumMu.Lock()
+	c, ok := r.checksums[path]
+	r.sumMu.Unlock()
+	if ok && c.size == fi.Size() && c.modTime.Equal(fi.ModTime()) {
+		return c.sum
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return ""
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	r.sumMu.Lock()
+	r.checksums[path] = fileChecksum{size: fi.Size(), modTime: fi.ModTime(), sum: sum}
+	r.sumMu.Unlock()
+	return sum
+}
+
+// ExportCSV writes every record whose start time is in [from, to) as CSV to w,
+// oldest first, reading rotated files as well as the current one. A zero to
+// means no upper bound. The files are opened under the lock but read without
+// it, so recording is not held up by an export.
+func (r *ProofOfPlayRecorder) ExportCSV(w io.Writer, from, to time.Time) error {
+	files, err := r.openLogs()
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"player", "file", "sha256", "start", "end", "duration_ms", "reason", "error"}); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := exportProofOfPlayFile(cw, f, from, to); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// openLogs opens the backups, oldest first, and the active log.
+func (r *ProofOfPlayRecorder) openLogs() ([]*os.File, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.backups()
+	names := make([]string, 0, n+1)
+	for i := n; i >= 1; i-- {
+		names = append(names, r.backupName(i))
+	}
+	names = append(names, r.path)
+	files := make([]*os.File, 0, len(names))
+	for _, name := range names {
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return files, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func exportProofOfPlayFile(cw *csv.Writer, f *os.File, from, to time.Time) error {
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec ProofOfPlayRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue // skip a line truncated by a power cut
+		}
+		if rec.Start.Before(from) || (!to.IsZero() && !rec.Start.Before(to)) {
+			continue
+		}
+		err := cw.Write([]string{
+			rec.Player,
+			rec.File,
+			rec.Checksum,
+			rec.Start.Format(time.RFC3339Nano),
+			rec.End.Format(time.RFC3339Nano),
+			strconv.FormatInt(rec.End.Sub(rec.Start).Milliseconds(), 10),
+			rec.Reason,
+			rec.Error,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Close closes the log file.
+func (r *ProofOfPlayRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// SetProofOfPlay attaches a recorder that receives one record per completed or
+// aborted playback. Passing nil detaches it. The recorder is not closed by the
+// player.
+func (p *Player) SetProofOfPlay(rec *ProofOfPlayRecorder) {
+	p.eventsMu.Lock()
+	defer p.eventsMu.Unlock()
+	p.proofOfPlay = rec
+}
+
+// proofOfPlayJob is an EventFinished waiting to be recorded by rec.
+type proofOfPlayJob struct {
+	rec *ProofOfPlayRecorder
+	ev  PlayerEvent
+}
+
+// writeProofOfPlay records the queued playbacks in the order they finished
+// until the queue is empty.
+func (p *Player) writeProofOfPlay() {
+	defer p.recording.Done()
+	for {
+		p.eventsMu.Lock()
+		if len(p.popQueue) == 0 {
+			p.popWriting = false
+			p.eventsMu.Unlock()
+			return
+		}
+		job := p.popQueue[0]
+		p.popQueue = p.popQueue[1:]
+		p.eventsMu.Unlock()
+		p.recordProofOfPlay(job.rec, job.ev)
+	}
+}
+
+// recordProofOfPlay turns a finished playback event into a proof-of-play
+// record. A file that failed to start was not played and is not recorded.
+func (p *Player) recordProofOfPlay(rec *ProofOfPlayRecorder, ev PlayerEvent) {
+	pop := ProofOfPlayRecord{
+		Player: p.dbusName,
+		File:   ev.Path,
+		Start:  ev.StartTime,
+		End:    ev.Time,
+	}
+	switch {
+	case ev.Interrupted:
+		pop.Reason = ReasonInterrupted
+	case ev.Err == nil:
+		pop.Reason = ReasonCompleted
+	default:
+		pop.Reason = ReasonFailed
+	}
+	if ev.Err != nil {
+		pop.Error = ev.Err.Error()
+	}
+	if err := rec.Record(pop); err != nil {
+		slogrus.Errorf("Can not record proof of play for %s: %s", ev.Path, err)
+	}
+}
@@ -0,0 +1,187 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// exportRows returns the CSV rows r exports for [from, to), without the
+// header.
+func exportRows(t *testing.T, r *goomx.ProofOfPlayRecorder, from, to time.Time) [][]string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.ExportCSV(&buf, from, to); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) == 0 || rows[0][0] != "player" {
+		t.Fatalf("export has no header: %v", rows)
+	}
+	return rows[1:]
+}
+
+// record writes n records, a second apart from start, named by their number.
+func record(t *testing.T, r *goomx.ProofOfPlayRecorder, start time.Time, n int) {
+	t.Helper()
+	for i := range n {
+		at := start.Add(time.Duration(i) * time.Second)
+		rec := goomx.ProofOfPlayRecord{Player: "p", File: strconv.Itoa(i), Checksum: "-", Start: at, End: at.Add(time.Second), Reason: goomx.ReasonCompleted}
+		if err := r.Record(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProofOfPlayRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pop.log")
+	r, err := goomx.NewProofOfPlayRecorder(path, 300, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	record(t, r, start, 20)
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more backups kept than the maximum")
+	}
+	rows := exportRows(t, r, time.Time{}, time.Time{})
+	if len(rows) == 0 || len(rows) >= 20 {
+		t.Fatalf("exported %d rows of 20, want the rotated-out ones dropped", len(rows))
+	}
+	for i, row := range rows {
+		if want := strconv.Itoa(20 - len(rows) + i); row[1] != want {
+			t.Fatalf("row %d is record %s, want %s: oldest first", i, row[1], want)
+		}
+	}
+}
+
+func TestProofOfPlayKeepAll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pop.log")
+	r, err := goomx.NewProofOfPlayRecorder(path, 300, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	record(t, r, start, 20)
+
+	if _, err := os.Stat(path + ".3"); err != nil {
+		t.Errorf("backups removed with maxBackups 0: %v", err)
+	}
+	if rows := exportRows(t, r, time.Time{}, time.Time{}); len(rows) != 20 {
+		t.Errorf("exported %d rows, want 20", len(rows))
+	}
+	if rows := exportRows(t, r, start.Add(5*time.Second), start.Add(8*time.Second)); len(rows) != 3 || rows[0][1] != "5" {
+		t.Errorf("exported %v for [5s, 8s), want records 5 to 7", rows)
+	}
+}
+
+func TestProofOfPlayClosed(t *testing.T) {
+	r, err := goomx.NewProofOfPlayRecorder(filepath.Join(t.TempDir(), "pop.log"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if err := r.Record(goomx.ProofOfPlayRecord{File: "a"}); err == nil {
+		t.Errorf("Record after Close succeeded")
+	}
+}
+
+func TestProofOfPlayPlayer(t *testing.T) {
+	if harnessErr != nil {
+		t.Skip("no fake omxplayer: ", harnessErr)
+	}
+	r, err := goomx.NewProofOfPlayRecorder(filepath.Join(t.TempDir(), "pop.log"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	p, err := goomx.NewPlayerWithConfig(harness.Config(goomxtest.Script{Duration: 200 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetProofOfPlay(r)
+	events := p.Events()
+	list := clips(t, "a.mp4", "b.mp4")
+	p.ConfigureNewPlaylist(list)
+	p.Play()
+	collect(t, events, goomx.EventFinished, 2)
+	collect(t, events, goomx.EventStarted, 1)
+	if err := p.Close(t.Context()); err != nil { // interrupts the third clip
+		t.Fatal(err)
+	}
+
+	rows := exportRows(t, r, time.Time{}, time.Time{})
+	if len(rows) != 3 {
+		t.Fatalf("recorded %v, want 3 records", rows)
+	}
+	sum := sha256.Sum256([]byte("a.mp4"))
+	for i, want := range []struct{ file, reason string }{
+		{list[0], goomx.ReasonCompleted},
+		{list[1], goomx.ReasonCompleted},
+		{list[0], goomx.ReasonInterrupted},
+	} {
+		row := rows[i]
+		if row[0] != p.DbusName() || row[1] != want.file || row[6] != want.reason {
+			t.Errorf("record %d = %v, want %s %s", i, row, want.file, want.reason)
+		}
+		if want.file == list[0] && row[2] != hex.EncodeToString(sum[:]) {
+			t.Errorf("record %d has checksum %s", i, row[2])
+		}
+	}
+}
+
+// TestProofOfPlayOrder plays a large clip, slow to checksum, before short
+// ones; the records must still be written in the order the clips played.
+func TestProofOfPlayOrder(t *testing.T) {
+	r, err := goomx.NewProofOfPlayRecorder(filepath.Join(t.TempDir(), "pop.log"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	p := newPlayer(t, goomxtest.Script{Duration: 50 * time.Millisecond}, func(cfg *goomx.PlayerConfig) {
+		cfg.PlaybackMode = goomx.ModeOnce
+	})
+	p.SetProofOfPlay(r)
+	events := p.Events()
+	list := clips(t, "a.mp4", "b.mp4", "c.mp4")
+	if err := os.Truncate(list[0], 256<<20); err != nil {
+		t.Fatal(err)
+	}
+	p.ConfigureNewPlaylist(list)
+	p.Play()
+	collect(t, events, goomx.EventPlaylistEnded, 1)
+	if err := p.Close(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := exportRows(t, r, time.Time{}, time.Time{})
+	var files []string
+	for _, row := range rows {
+		files = append(files, row[1])
+	}
+	if !equal(files, list) {
+		t.Errorf("recorded %v, want %v", files, list)
+	}
+}
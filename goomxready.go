@@ -0,0 +1,181 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	dbus "github.com/godbus/dbus"
+	"github.com/sonnt85/gosutils/slogrus"
+)
+
+const (
+	// socketRetryInterval is how often a socket that exists but refuses
+	// connections is retried.
+	socketRetryInterval = 100 * time.Millisecond
+
+	ifaceDbus             = "org.freedesktop.DBus"
+	cmdAddMatch           = ifaceDbus + ".AddMatch"
+	cmdRemoveMatch        = ifaceDbus + ".RemoveMatch"
+	cmdNameHasOwner       = ifaceDbus + ".NameHasOwner"
+	signalNameOwnerChange = ifaceDbus + ".NameOwnerChanged"
+)
+
+// readyFlag is a boolean whose transitions can be waited for on a channel.
+type readyFlag struct {
+	mu      sync.Mutex
+	ready   bool
+	readyCh chan struct{} // closed while ready
+	quitCh  chan struct{} // closed while not ready
+}
+
+func newReadyFlag() *readyFlag {
+	r := &readyFlag{readyCh: make(chan struct{}), quitCh: make(chan struct{})}
+	close(r.quitCh)
+	return r
+}
+
+func (r *readyFlag) set(ready bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ready == ready {
+		return
+	}
+	r.ready = ready
+	if ready {
+		close(r.readyCh)
+		r.quitCh = make(chan struct{})
+	} else {
+		close(r.quitCh)
+		r.readyCh = make(chan struct{})
+	}
+}
+
+func (r *readyFlag) get() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ready
+}
+
+// wait returns a channel that is closed once the flag equals ready.
+func (r *readyFlag) wait(ready bool) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ready {
+		return r.readyCh
+	}
+	return r.quitCh
+}
+
+// watchBusName tracks ownership of the D-Bus name on conn through
+// NameOwnerChanged signals and updates flag until ctx is done.
+func watchBusName(ctx context.Context, conn *dbus.Conn, name string, flag *readyFlag) {
+	defer flag.set(false)
+	rule := fmt.Sprintf("type='signal',sender='%s',interface='%s',member='NameOwnerChanged',arg0='%s'", ifaceDbus, ifaceDbus, name)
+	daemon := conn.BusObject()
+	if call := daemon.Call(cmdAddMatch, 0, rule); call.Err != nil {
+		slogrus.Print("can not watch dbus name: ", call.Err)
+		return
+	}
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+	defer func() {
+		conn.RemoveSignal(signals)
+		daemon.Go(cmdRemoveMatch, dbus.FlagNoReplyExpected, nil, rule)
+	}()
+	// the name may have been claimed before the match was added
+	var owned bool
+	if err := daemon.Call(cmdNameHasOwner, 0, name).Store(&owned); err == nil && owned {
+		flag.set(true)
+	}
+	for {
+		select {
+		case sig, ok := <-signals:
+			if !ok {
+				return
+			}
+			if sig.Name != signalNameOwnerChange || len(sig.Body) < 3 {
+				continue
+			}
+			if n, _ := sig.Body[0].(string); n != name {
+				continue
+			}
+			newOwner, _ := sig.Body[2].(string)
+			flag.set(newOwner != "")
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// waitDbusFile waits, using inotify, for one of omxplayer's D-Bus files to be
+// written and returns its contents. If nothing is written before the deadline
+// of ctx, ErrDbusTimeout is returned; if ctx is cancelled, the player is
+// closing and ErrClosed is returned.
+func waitDbusFile(ctx context.Context, path string) (string, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return "", err
+	}
+	defer watcher.Close()
+	// watch before the first read so a write in between is not missed
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		return "", err
+	}
+	for {
+		contents, err := os.ReadFile(path)
+		if err == nil && len(strings.TrimSpace(string(contents))) != 0 {
+			return strings.TrimSpace(string(contents)), nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		select {
+		case <-watcher.Events: // any change in the directory triggers a re-read
+		case err = <-watcher.Errors:
+			return "", err
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return "", fmt.Errorf("%w: %s", ErrDbusTimeout, path)
+			}
+			return "", fmt.Errorf("%w: %s", ErrClosed, path)
+		}
+	}
+}
+
+// waitUnixSocket waits, using inotify, for a program to listen on the unix
+// socket at path and returns a connection to it. If nothing listens before ctx
+// is done, an error wrapping ctx.Err() is returned.
+func waitUnixSocket(ctx context.Context, path string) (net.Conn, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	defer watcher.Close()
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	for {
+		conn, err := d.DialContext(ctx, "unix", path)
+		if err == nil {
+			return conn, nil
+		}
+		select {
+		case <-watcher.Events:
+		case <-time.After(socketRetryInterval): // bound before listening
+		case err = <-watcher.Errors:
+			return nil, err
+		case <-ctx.Done():
+			return nil, fmt.Errorf("connect %s: %w", path, ctx.Err())
+		}
+	}
+}
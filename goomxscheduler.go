@@ -0,0 +1,161 @@
+//go:build linux
+
+package goomx
+
+import (
+	"sync"
+)
+
+// Scheduler chooses the playlist entry played next in place of the playback
+// mode; see SetScheduler.
+type Scheduler interface {
+	// Next returns the index in entries of the entry to play after
+	// entries[current], or -1 to fall back to the playback mode. It is also
+	// used to look ahead, so it must not change the scheduler's state.
+	Next(entries []PlaylistItem, current int) int
+	// Played records that entries[i] is being played.
+	Played(entries []PlaylistItem, i int)
+}
+
+// SetScheduler makes s choose the entry played after the current one, from
+// the playlist entries with their settings, instead of the playback mode. A
+// seek still plays the entry it moves to. nil restores the playback mode.
+func (p *Player) SetScheduler(s Scheduler) {
+	p.queueMu.Lock()
+	defer p.queueMu.Unlock()
+	p.scheduler = s
+}
+
+// scheduledIndex returns the index s chooses after the current entry r, or -1.
+// queueMu must be held by the caller.
+func (p *Player) scheduledIndex(s Scheduler, r int) int {
+	entries := p.GetPlaylistItems()
+	if r >= len(entries) {
+		return -1
+	}
+	i := s.Next(entries, r)
+	if i >= len(entries) {
+		return -1
+	}
+	return i
+}
+
+// WeightedScheduler is an ad rotation Scheduler. Entries are played in
+// proportion to their share of voice: an entry with a Share gets that
+// percentage of the plays, and the remaining plays are split between the
+// other entries by Weight. The order is spread evenly (smooth weighted
+// round-robin) and keeps the separations, relaxing them only when no entry
+// could play otherwise, tag separation first. It must be used by one player
+// only.
+type WeightedScheduler struct {
+	// Separation is how many other entries must play between two plays of
+	// the same clip.
+	Separation int
+	// TagSeparation is how many other entries must play between two clips
+	// with the same Tag, e.g. from the same advertiser.
+	TagSeparation int
+
+	mu     sync.Mutex
+	credit map[string]float64 // by path
+	recent []PlaylistItem     // last played, most recent last
+}
+
+// NewWeightedScheduler returns a WeightedScheduler with the given
+// separations.
+func NewWeightedScheduler(separation, tagSeparation int) *WeightedScheduler {
+	return &WeightedScheduler{Separation: separation, TagSeparation: tagSeparation}
+}
+
+// shares returns the fraction of the plays each entry should get. Shares are
+// scaled to add up to 100% if they add up to more, or if every entry has one.
+func shares(entries []PlaylistItem) []float64 {
+	var shared, weights float64
+	for _, e := range entries {
+		if e.Share > 0 {
+			shared += e.Share / 100
+		} else {
+			weights += weight(e)
+		}
+	}
+	scale := shared > 1 || weights == 0
+	rest := 1 - shared
+	if scale {
+		rest = 0
+	}
+	out := make([]float64, len(entries))
+	for i, e := range entries {
+		switch {
+		case e.Share > 0 && scale:
+			out[i] = e.Share / 100 / shared
+		case e.Share > 0:
+			out[i] = e.Share / 100
+		case weights > 0:
+			out[i] = rest * weight(e) / weights
+		}
+	}
+	return out
+}
+
+// weight returns the Weight of e, 1 if unset.
+func weight(e PlaylistItem) float64 {
+	if e.Weight > 0 {
+		return e.Weight
+	}
+	return 1
+}
+
+// Next returns the entry with the most credit that keeps the separations.
+func (s *WeightedScheduler) Next(entries []PlaylistItem, current int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	target := shares(entries)
+	best, bestCredit := -1, 0.0
+	for relax := 0; relax < 3 && best < 0; relax++ {
+		for i, e := range entries {
+			if target[i] <= 0 {
+				continue
+			}
+			if relax < 2 && s.playedWithin(s.Separation, func(r PlaylistItem) bool { return r.Path == e.Path }) {
+				continue
+			}
+			if relax < 1 && e.Tag != "" && s.playedWithin(s.TagSeparation, func(r PlaylistItem) bool { return r.Tag == e.Tag }) {
+				continue
+			}
+			if c := s.credit[e.Path] + target[i]; best < 0 || c > bestCredit {
+				best, bestCredit = i, c
+			}
+		}
+	}
+	return best
+}
+
+// playedWithin reports whether one of the last n entries played matches.
+func (s *WeightedScheduler) playedWithin(n int, match func(PlaylistItem) bool) bool {
+	for i := len(s.recent) - 1; i >= 0 && i >= len(s.recent)-n; i-- {
+		if match(s.recent[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Played gives every entry its share of credit and charges entries[i] for a
+// play.
+func (s *WeightedScheduler) Played(entries []PlaylistItem, i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credit := make(map[string]float64, len(entries)) // forgets removed entries
+	for j, share := range shares(entries) {
+		path := entries[j].Path
+		if _, ok := credit[path]; !ok {
+			credit[path] = s.credit[path]
+		}
+		credit[path] += share
+	}
+	credit[entries[i].Path]--
+	s.credit = credit
+	s.recent = append(s.recent, entries[i])
+	if keep := max(s.Separation, s.TagSeparation); len(s.recent) > keep {
+		s.recent = append(s.recent[:0], s.recent[len(s.recent)-keep:]...)
+	}
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// reverseScheduler plays the playlist backwards.
+type reverseScheduler struct{}
+
+func (reverseScheduler) Next(entries []goomx.PlaylistItem, current int) int {
+	return (current + len(entries) - 1) % len(entries)
+}
+
+func (reverseScheduler) Played([]goomx.PlaylistItem, int) {}
+
+// schedule returns the paths s chooses for n plays of entries.
+func schedule(s goomx.Scheduler, entries []goomx.PlaylistItem, n int) []string {
+	played := make([]string, 0, n)
+	current := 0
+	for range n {
+		current = s.Next(entries, current)
+		s.Played(entries, current)
+		played = append(played, entries[current].Path)
+	}
+	return played
+}
+
+func TestWeightedSchedulerShares(t *testing.T) {
+	entries := []goomx.PlaylistItem{
+		{Path: "a", Share: 50},
+		{Path: "b", Weight: 3},
+		{Path: "c"},
+	}
+	counts := make(map[string]int)
+	for _, path := range schedule(goomx.NewWeightedScheduler(0, 0), entries, 40) {
+		counts[path]++
+	}
+	if counts["a"] != 20 || counts["b"] != 15 || counts["c"] != 5 {
+		t.Errorf("plays %v, want a:20 b:15 c:5", counts)
+	}
+}
+
+func TestWeightedSchedulerSeparation(t *testing.T) {
+	entries := []goomx.PlaylistItem{
+		{Path: "a", Weight: 4, Tag: "x"},
+		{Path: "b", Tag: "x"},
+		{Path: "c", Tag: "y"},
+		{Path: "d", Tag: "y"},
+	}
+	played := schedule(goomx.NewWeightedScheduler(1, 1), entries, 30)
+	tags := map[string]string{"a": "x", "b": "x", "c": "y", "d": "y"}
+	for i := 1; i < len(played); i++ {
+		if tags[played[i]] == tags[played[i-1]] {
+			t.Fatalf("%s and %s of the same tag played in a row: %v", played[i-1], played[i], played)
+		}
+	}
+}
+
+func TestWeightedSchedulerRelaxes(t *testing.T) {
+	// a single entry cannot keep any separation, so it plays anyway
+	entries := []goomx.PlaylistItem{{Path: "a", Tag: "x"}}
+	if got := schedule(goomx.NewWeightedScheduler(2, 2), entries, 3); !equal(got, []string{"a", "a", "a"}) {
+		t.Errorf("played %v, want a a a", got)
+	}
+}
+
+func TestSetScheduler(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: 150 * time.Millisecond}, nil)
+	p.SetScheduler(reverseScheduler{})
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4", "c.mp4"))
+	p.Play()
+
+	if got, want := paths(collect(t, events, goomx.EventStarted, 4)), []string{"a.mp4", "c.mp4", "b.mp4", "a.mp4"}; !equal(got, want) {
+		t.Errorf("started %v, want %v", got, want)
+	}
+}
+
+// TestSchedulerModeOnce stops after as many plays as the playlist has
+// entries, in whatever order the scheduler chose.
+func TestSchedulerModeOnce(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: 150 * time.Millisecond}, func(cfg *goomx.PlayerConfig) {
+		cfg.PlaybackMode = goomx.ModeOnce
+	})
+	p.SetScheduler(reverseScheduler{})
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4", "c.mp4"))
+	p.Play()
+
+	var started []goomx.PlayerEvent
+	timeout := time.After(eventTimeout)
+	for ended := false; !ended; {
+		select {
+		case ev := <-events:
+			switch ev.Type {
+			case goomx.EventStarted:
+				started = append(started, ev)
+			case goomx.EventPlaylistEnded:
+				ended = true
+			}
+		case <-timeout:
+			t.Fatalf("playlist did not end, started %v", paths(started))
+		}
+	}
+	if got, want := paths(started), []string{"a.mp4", "c.mp4", "b.mp4"}; !equal(got, want) {
+		t.Errorf("started %v, want %v", got, want)
+	}
+	if p.PlayIsActive() {
+		t.Errorf("playback is active after the end of the playlist")
+	}
+}
@@ -0,0 +1,135 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/sonnt85/gosutils/slogrus"
+)
+
+// streamSchemes are the URL schemes played as network streams before, and in
+// addition to, the ones omxplayer reports in SupportedUriSchemes.
+var streamSchemes = []string{"http", "https", "rtsp", "rtmp", "rtp", "udp", "mms", "mmsh"}
+
+// liveSchemes are the URL schemes of live streams, which get LiveStreamArgs.
+var liveSchemes = []string{"rtsp", "rtmp", "rtp", "udp"}
+
+// urlScheme returns the lower-cased scheme of path if it is a URL.
+func urlScheme(path string) string {
+	i := strings.Index(path, "://")
+	if i <= 0 {
+		return ""
+	}
+	scheme := strings.ToLower(path[:i])
+	for _, r := range scheme {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.') {
+			return ""
+		}
+	}
+	return scheme
+}
+
+// playLocation returns what is played for the playlist entry path: the path
+// of a file URL, otherwise path itself.
+func playLocation(path string) string {
+	if urlScheme(path) == "file" {
+		return resolveLocation(path, "")
+	}
+	return path
+}
+
+// isStreamURL reports whether path is a URL of a network stream.
+func (p *Player) isStreamURL(path string) bool {
+	scheme := urlScheme(path)
+	if scheme == "" || scheme == "file" {
+		return false
+	}
+	if slices.Contains(streamSchemes, scheme) {
+		return true
+	}
+	p.itemsMu.Lock()
+	defer p.itemsMu.Unlock()
+	return slices.Contains(p.uriSchemes, scheme)
+}
+
+// isLiveStream reports whether the stream path is live rather than on
+// demand: RTSP, RTMP, RTP, UDP or an HLS (.m3u8) playlist.
+func isLiveStream(path string) bool {
+	if slices.Contains(liveSchemes, urlScheme(path)) {
+		return true
+	}
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	return strings.HasSuffix(strings.ToLower(path), ".m3u8")
+}
+
+// learnURISchemes records the URL schemes the omxplayer process of s
+// supports, once per player.
+func (p *Player) learnURISchemes(ctx context.Context, s *omxSession) {
+	p.itemsMu.Lock()
+	known := p.uriSchemes != nil
+	p.itemsMu.Unlock()
+	if known {
+		return
+	}
+	schemes, err := dbusValueOn[[]string](ctx, s.bus, propSupportedURISchemes)
+	if err != nil {
+		return
+	}
+	for i := range schemes {
+		schemes[i] = strings.ToLower(schemes[i])
+	}
+	p.itemsMu.Lock()
+	p.uriSchemes = schemes
+	p.itemsMu.Unlock()
+}
+
+// streamArgs returns the omxplayer arguments for the stream path.
+func (p *Player) streamArgs(path string) []string {
+	args := append([]string(nil), p.config.StreamArgs...)
+	if isLiveStream(path) {
+		args = append(args, p.config.LiveStreamArgs...)
+	}
+	return args
+}
+
+// settle handles the end of the playback of file with result err, stopped
+// if it was stopped rather than ending on its own. If file is a stream to
+// restart it waits StreamRetryDelay and returns true; otherwise it records
+// the result and waits out any crash backoff.
+func (p *Player) settle(file FilePlay, stopped bool, err error, retries *int, gen uint64) bool {
+	if p.retryStream(file, stopped, err, retries, gen) {
+		if err != nil {
+			slogrus.Printf("Stream %s failed, restarting (%d/%d): %s\n", file.pathFile, *retries, p.config.StreamRetries, err)
+		} else {
+			slogrus.Printf("Live stream %s ended, restarting (%d/%d)\n", file.pathFile, *retries, p.config.StreamRetries)
+		}
+		p.sleep(p.config.StreamRetryDelay)
+		return true
+	}
+	p.sleep(p.recordPlayback(file.pathFile, err))
+	return false
+}
+
+// retryStream reports whether file, a stream that ended with err, should be
+// restarted rather than the playlist moving on, counting the attempt in
+// retries. A live stream is restarted even if it ended cleanly, since it has
+// no end of its own; a stream that was stopped is not. gen is the loopGen
+// value when the stream was first started; a change means the user moved on.
+func (p *Player) retryStream(file FilePlay, stopped bool, err error, retries *int, gen uint64) bool {
+	if !file.isStreamLink || stopped || *retries >= p.config.StreamRetries {
+		return false
+	}
+	if err == nil && !isLiveStream(file.pathFile) { // played to its end
+		return false
+	}
+	if p.ctx.Err() != nil || !p.enablePlay.Get() || p.loopGen.Load() != gen {
+		return false
+	}
+	*retries++
+	return true
+}
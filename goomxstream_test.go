@@ -0,0 +1,55 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// TestLiveStreamRestarted plays a live stream that ends cleanly, which is
+// restarted StreamRetries times, and an on-demand one, which is not.
+func TestLiveStreamRestarted(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: 200 * time.Millisecond}, func(cfg *goomx.PlayerConfig) {
+		cfg.StreamRetries = 2
+		cfg.StreamRetryDelay = 50 * time.Millisecond
+		cfg.PlaybackMode = goomx.ModeOnce
+	})
+	events := p.Events()
+	const live, vod = "rtsp://camera/live", "http://example.com/clip.mp4"
+	p.ConfigureNewPlaylist(append([]string{live, vod}, clips(t, "b.mp4")...))
+	p.Play()
+
+	var started, finished []goomx.PlayerEvent
+	timeout := time.After(eventTimeout)
+	for len(finished) < 5 {
+		select {
+		case ev := <-events:
+			switch ev.Type {
+			case goomx.EventStarted:
+				started = append(started, ev)
+			case goomx.EventFinished:
+				finished = append(finished, ev)
+			}
+		case <-timeout:
+			t.Fatalf("%d entries finished, want 5", len(finished))
+		}
+	}
+	want := []string{live, live, live, vod}
+	for i, path := range want {
+		if started[i].Path != path {
+			t.Fatalf("started %v, want %v and then b.mp4", paths(started), want)
+		}
+	}
+	if got := paths(started[4:]); !equal(got, []string{"b.mp4"}) {
+		t.Errorf("started %v after the streams, want b.mp4", got)
+	}
+	for _, ev := range finished {
+		if ev.Err != nil {
+			t.Errorf("%s finished with %v, want a clean exit", ev.Path, ev.Err)
+		}
+	}
+}
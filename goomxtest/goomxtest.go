@@ -0,0 +1,225 @@
+//go:build linux
+
+// Package goomxtest runs goomx players against a fake omxplayer
+// (cmd/fakeomxplayer) registered on a private dbus-daemon, so the playlist and
+// control logic can be exercised on any Linux machine without omxplayer, a
+// display or the user's session bus.
+//
+//	h, err := goomxtest.New("")
+//	defer h.Close()
+//	h.SetScript(goomxtest.Script{Duration: time.Second})
+//	player, err := h.NewPlayer("")
+//
+// Each player gets the script and D-Bus files of its own through its
+// PlayerConfig, so players and harnesses do not affect each other and tests
+// may run in parallel.
+package goomxtest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sonnt85/goomx"
+)
+
+// fakePackage is the import path of the fake omxplayer.
+const fakePackage = "github.com/sonnt85/goomx/cmd/fakeomxplayer"
+
+// daemonTimeout is how long dbus-daemon gets to start listening.
+const daemonTimeout = 5 * time.Second
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// Script is how the fake omxplayer behaves in the playbacks that follow.
+type Script struct {
+	Duration   time.Duration // clip length, 10s if zero
+	ExitCode   int           // exit status when the clip ends
+	StartDelay time.Duration // delay before the D-Bus name is claimed
+	StallAfter time.Duration // position at which playback freezes, never if zero
+}
+
+// Harness is a private dbus-daemon plus a fake omxplayer binary.
+type Harness struct {
+	Dir     string // scratch directory holding the bus socket, D-Bus files and call log
+	Address string // address of the private bus
+	Binary  string // fake omxplayer executable
+	Log     string // file the fake appends every method call to
+
+	daemon *exec.Cmd
+	mu     sync.Mutex
+	script Script // for the players created next
+	seq    int    // players configured so far
+}
+
+// New starts a dbus-daemon listening in a new temporary directory. binary is
+// a prebuilt fake omxplayer; if empty, the fake is built with the go tool.
+func New(binary string) (h *Harness, err error) {
+	dir, err := os.MkdirTemp("", "goomxtest")
+	if err != nil {
+		return nil, err
+	}
+	h = &Harness{
+		Dir:    dir,
+		Binary: binary,
+		Log:    filepath.Join(dir, "calls.log"),
+	}
+	defer func() {
+		if err != nil {
+			h.Close()
+			h = nil
+		}
+	}()
+	if h.Binary == "" {
+		h.Binary = filepath.Join(dir, "omxplayer")
+		out, err := exec.Command("go", "build", "-o", h.Binary, fakePackage).CombinedOutput()
+		if err != nil {
+			return h, fmt.Errorf("build fake omxplayer: %w: %s", err, out)
+		}
+	}
+	if err = h.startDaemon(); err != nil {
+		return h, err
+	}
+	return h, nil
+}
+
+// startDaemon starts dbus-daemon and waits for it to print its address.
+func (h *Harness) startDaemon() error {
+	conf := filepath.Join(h.Dir, "bus.conf")
+	if err := os.WriteFile(conf, []byte(fmt.Sprintf(busConfig, filepath.Join(h.Dir, "bus"))), 0644); err != nil {
+		return err
+	}
+	h.daemon = exec.Command("dbus-daemon", "--config-file="+conf, "--nofork", "--print-address")
+	out, err := h.daemon.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = h.daemon.Start(); err != nil {
+		return fmt.Errorf("start dbus-daemon: %w", err)
+	}
+	address := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(out).ReadString('\n')
+		address <- strings.TrimSpace(line)
+	}()
+	select {
+	case h.Address = <-address:
+	case <-time.After(daemonTimeout):
+	}
+	if h.Address == "" {
+		return errors.New("dbus-daemon did not report its address")
+	}
+	return nil
+}
+
+// SetScript sets the script of the players NewPlayer creates from now on.
+// Players already created keep theirs.
+func (h *Harness) SetScript(s Script) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.script = s
+}
+
+// Config returns the default player configuration with the fake as
+// omxplayer, D-Bus files of its own in Dir and s passed to the fake through
+// PlayerConfig.Env. Change it as needed and pass it to
+// goomx.NewPlayerWithConfig.
+func (h *Harness) Config(s Script) goomx.PlayerConfig {
+	h.mu.Lock()
+	h.seq++
+	dbusFile := filepath.Join(h.Dir, "omxplayerdbus."+strconv.Itoa(h.seq))
+	h.mu.Unlock()
+	cfg := goomx.DefaultPlayerConfig()
+	cfg.OmxplayerBinary = h.Binary
+	cfg.DbusAddressFile = dbusFile
+	cfg.DbusPidFile = dbusFile + ".pid"
+	cfg.Env = []string{
+		"FAKEOMX_DBUS_ADDRESS=" + h.Address,
+		"FAKEOMX_DBUS_FILE=" + dbusFile,
+		"FAKEOMX_LOG=" + h.Log,
+		"FAKEOMX_EXIT_CODE=" + strconv.Itoa(s.ExitCode),
+		"FAKEOMX_START_DELAY=" + s.StartDelay.String(),
+		"FAKEOMX_STALL_AFTER=" + s.StallAfter.String(),
+	}
+	if s.Duration > 0 {
+		cfg.Env = append(cfg.Env, "FAKEOMX_DURATION="+s.Duration.String())
+	}
+	return cfg
+}
+
+// NewPlayer creates a player instance, as goomx.NewPlayerInstance does, that
+// starts the fake instead of omxplayer and finds it on the private bus. The
+// fake follows the script last set with SetScript.
+func (h *Harness) NewPlayer(dbusName string, args ...string) (*goomx.Player, error) {
+	h.mu.Lock()
+	s := h.script
+	h.mu.Unlock()
+	cfg := h.Config(s)
+	cfg.DbusName = dbusName
+	cfg.Args = args
+	return goomx.NewPlayerWithConfig(cfg)
+}
+
+// Calls returns the method calls the fake received so far, one per entry, as
+// "<dbus name> <interface>.<method> [args...]".
+func (h *Harness) Calls() ([]string, error) {
+	data, err := os.ReadFile(h.Log)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// WaitForCall waits until the fake received a call whose line contains
+// substr, or ctx is done.
+func (h *Harness) WaitForCall(ctx context.Context, substr string) error {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		calls, err := h.Calls()
+		if err != nil {
+			return err
+		}
+		for _, c := range calls {
+			if strings.Contains(c, substr) {
+				return nil
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for call %q: %w", substr, ctx.Err())
+		}
+	}
+}
+
+// Close stops the dbus-daemon and removes the scratch directory.
+func (h *Harness) Close() error {
+	if h.daemon != nil && h.daemon.Process != nil {
+		h.daemon.Process.Kill()
+		h.daemon.Wait()
+	}
+	return os.RemoveAll(h.Dir)
+}
@@ -0,0 +1,94 @@
+//go:build linux
+
+package goomxtest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+)
+
+func newHarness(t *testing.T) *Harness {
+	t.Helper()
+	h, err := New("")
+	if err != nil {
+		t.Skip("no fake omxplayer: ", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func TestConfig(t *testing.T) {
+	h := newHarness(t)
+	a, b := h.Config(Script{Duration: time.Second, ExitCode: 2}), h.Config(Script{})
+	if a.OmxplayerBinary != h.Binary {
+		t.Errorf("binary %s, want %s", a.OmxplayerBinary, h.Binary)
+	}
+	if a.DbusAddressFile == "" || a.DbusAddressFile == b.DbusAddressFile || a.DbusPidFile == b.DbusPidFile {
+		t.Errorf("players share D-Bus files: %s and %s", a.DbusAddressFile, b.DbusAddressFile)
+	}
+	for _, kv := range []string{"FAKEOMX_DURATION=1s", "FAKEOMX_EXIT_CODE=2", "FAKEOMX_DBUS_ADDRESS=" + h.Address, "FAKEOMX_DBUS_FILE=" + a.DbusAddressFile} {
+		if !slices.Contains(a.Env, kv) {
+			t.Errorf("environment %v lacks %s", a.Env, kv)
+		}
+	}
+	if slices.Contains(b.Env, "FAKEOMX_DURATION=1s") {
+		t.Errorf("script of one player leaked into another")
+	}
+	if err := a.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestPlayersInParallel plays two players with different scripts at once on
+// one harness.
+func TestPlayersInParallel(t *testing.T) {
+	h := newHarness(t)
+	h.SetScript(Script{Duration: 200 * time.Millisecond})
+	short, err := h.NewPlayer("org.mpris.MediaPlayer2.omxplayer.short")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetScript(Script{Duration: time.Minute})
+	long, err := h.NewPlayer("org.mpris.MediaPlayer2.omxplayer.long")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	defer long.Close(ctx)
+	defer short.Close(ctx)
+
+	clip := h.Binary // any existing file does
+	shortEvents, longEvents := short.Events(), long.Events()
+	short.ConfigureNewPlaylist([]string{clip})
+	long.ConfigureNewPlaylist([]string{clip})
+	short.Play()
+	long.Play()
+
+	finished := 0
+	for finished < 2 {
+		select {
+		case ev := <-shortEvents:
+			if ev.Type == goomx.EventFinished {
+				finished++
+			}
+		case ev := <-longEvents:
+			if ev.Type == goomx.EventFinished {
+				t.Fatalf("long clip finished after %v", ev.Duration)
+			}
+		case <-ctx.Done():
+			t.Fatal("short clip did not finish twice")
+		}
+	}
+	if err := h.WaitForCall(ctx, "org.mpris.MediaPlayer2.omxplayer.long org.freedesktop.DBus.Properties.Volume"); err != nil {
+		t.Error(err)
+	}
+	calls, err := h.Calls()
+	if err != nil || len(calls) == 0 {
+		t.Errorf("Calls() = %v, %v", calls, err)
+	}
+}
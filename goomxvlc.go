@@ -0,0 +1,338 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const exeCvlc = "cvlc"
+
+// vlcVolumeScale is VLC's volume for 100%.
+const vlcVolumeScale = 256
+
+// VlcBackend plays playlist entries with cvlc, controlled over VLC's HTTP
+// interface (https://wiki.videolan.org/VLC_HTTP_requests/), which listens on
+// a random localhost port with a random password. The password is passed in a
+// config file of its own, so VLC does not read the user's vlcrc.
+type VlcBackend struct {
+	// Binary is the VLC executable, "cvlc" if empty.
+	Binary string
+	// Args are passed to VLC before the player's args. If nil, VLC plays
+	// fullscreen without showing the title and exits at the end of the file.
+	Args []string
+}
+
+func (b *VlcBackend) Name() string { return "vlc" }
+
+func (b *VlcBackend) Start(ctx context.Context, file string, args, env []string) (Session, error) {
+	binary := b.Binary
+	if binary == "" {
+		binary = exeCvlc
+	}
+	pre := b.Args
+	if pre == nil {
+		pre = []string{"--fullscreen", "--no-video-title-show", "--play-and-exit"}
+	}
+	port, err := freeLocalPort()
+	if err != nil {
+		return nil, &PlayerError{Op: "start", Path: file, Err: err}
+	}
+	secret := make([]byte, 8)
+	if _, err = rand.Read(secret); err != nil {
+		return nil, &PlayerError{Op: "start", Path: file, Err: err}
+	}
+	password := hex.EncodeToString(secret)
+	// the command line is visible to everyone, the config file only to the
+	// user; VLC reads it at startup
+	rc, err := writeVlcrc(password)
+	if err != nil {
+		return nil, &PlayerError{Op: "start", Path: file, Err: err}
+	}
+	defer os.Remove(rc)
+	cmdArgs := make([]string, 0, len(pre)+len(args)+7)
+	cmdArgs = append(cmdArgs, pre...)
+	cmdArgs = append(cmdArgs, "--config="+rc, "--extraintf=http", "--http-host=127.0.0.1",
+		"--http-port="+strconv.Itoa(port))
+	cmdArgs = append(cmdArgs, args...)
+	cmdArgs = append(cmdArgs, "--", file)
+
+	s := &vlcSession{
+		cmd:      exec.Command(binary, cmdArgs...),
+		base:     fmt.Sprintf("http://127.0.0.1:%d/requests/status.json", port),
+		password: password,
+		exited:   make(chan struct{}),
+	}
+	s.cmd.Env = env
+	s.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err = s.cmd.Start(); err != nil {
+		return nil, startError(file, err)
+	}
+	go func() {
+		s.waitErr = s.cmd.Wait()
+		close(s.exited)
+	}()
+
+	// the HTTP interface gives no sign it is up, so it is polled
+	ticker := time.NewTicker(socketRetryInterval)
+	defer ticker.Stop()
+	for {
+		if _, err = s.status(ctx, nil); err == nil {
+			return s, nil
+		}
+		select {
+		case <-ticker.C:
+			continue
+		case <-s.exited:
+			var ee *exec.ExitError
+			if errors.As(s.waitErr, &ee) {
+				err = newExitError(file, ee.ProcessState)
+			}
+		case <-ctx.Done():
+			err = ctx.Err()
+			s.Kill()
+			<-s.exited
+		}
+		return nil, &PlayerError{Op: "vlc connect", Path: file, Err: err}
+	}
+}
+
+// writeVlcrc writes a VLC config file, readable by the user only, setting
+// the password of the HTTP interface, and returns its name.
+func writeVlcrc(password string) (string, error) {
+	f, err := os.CreateTemp("", "goomx-vlcrc-")
+	if err != nil {
+		return "", err
+	}
+	_, err = fmt.Fprintf(f, "[lua]\nhttp-password=%s\n", password)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// freeLocalPort returns a TCP port on localhost nothing listens on.
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// vlcStatus is the part of VLC's status.json the player uses.
+type vlcStatus struct {
+	State       string  `json:"state"`
+	Time        int64   `json:"time"`   // seconds
+	Length      int64   `json:"length"` // seconds
+	Volume      float64 `json:"volume"`
+	Information struct {
+		Category map[string]map[string]interface{} `json:"category"`
+	} `json:"information"`
+}
+
+// vlcSession is the Session of a VLC process.
+type vlcSession struct {
+	cmd      *exec.Cmd
+	base     string // status.json URL
+	password string
+	exited   chan struct{} // closed once cmd has been waited for
+	waitErr  error
+}
+
+// status runs the command in params, if any, and returns the resulting
+// status.
+func (s *vlcSession) status(ctx context.Context, params url.Values) (*vlcStatus, error) {
+	op := "vlc status"
+	if params != nil {
+		op = "vlc " + params.Get("command")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dbusCallTimeout)
+		defer cancel()
+	}
+	u := s.base
+	if params != nil {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, &PlayerError{Op: op, Err: err}
+	}
+	req.SetBasicAuth("", s.password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			err = fmt.Errorf("%w: %v", ErrNotRunning, err)
+		}
+		return nil, &PlayerError{Op: op, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &PlayerError{Op: op, Err: fmt.Errorf("%w: %s", ErrUnexpectedReply, resp.Status)}
+	}
+	var st vlcStatus
+	if err = json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return nil, &PlayerError{Op: op, Err: fmt.Errorf("%w: %v", ErrUnexpectedReply, err)}
+	}
+	return &st, nil
+}
+
+func (s *vlcSession) command(ctx context.Context, command string, val string) error {
+	params := url.Values{"command": {command}}
+	if val != "" {
+		params.Set("val", val)
+	}
+	_, err := s.status(ctx, params)
+	return err
+}
+
+func (s *vlcSession) Wait() error {
+	<-s.exited
+	return s.waitErr
+}
+
+func (s *vlcSession) Kill() error {
+	if s.cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-s.cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// Quit stops playback, which makes VLC exit when started with
+// --play-and-exit. The HTTP interface has no quit command.
+func (s *vlcSession) Quit(ctx context.Context) error {
+	return s.command(ctx, "pl_stop", "")
+}
+
+func (s *vlcSession) Pause(ctx context.Context) error {
+	return s.command(ctx, "pl_forcepause", "")
+}
+
+func (s *vlcSession) Play(ctx context.Context) error {
+	return s.command(ctx, "pl_forceresume", "")
+}
+
+func (s *vlcSession) PlayPause(ctx context.Context) error {
+	return s.command(ctx, "pl_pause", "")
+}
+
+func (s *vlcSession) PlaybackStatus(ctx context.Context) (string, error) {
+	st, err := s.status(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	if st.State == "paused" {
+		return "Paused", nil
+	}
+	return "Playing", nil
+}
+
+// Seek seeks by offset, rounded to whole seconds.
+func (s *vlcSession) Seek(ctx context.Context, offset int64) (int64, error) {
+	if err := s.command(ctx, "seek", fmt.Sprintf("%+d", offset/1e6)); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+// SetPosition seeks to position, rounded to whole seconds.
+func (s *vlcSession) SetPosition(ctx context.Context, path string, position int64) (int64, error) {
+	if err := s.command(ctx, "seek", strconv.FormatInt(position/1e6, 10)); err != nil {
+		return 0, err
+	}
+	return position, nil
+}
+
+func (s *vlcSession) Volume(ctx context.Context, volume ...float64) (float64, error) {
+	var params url.Values
+	if len(volume) != 0 {
+		params = url.Values{"command": {"volume"}, "val": {strconv.Itoa(int(volume[0] * vlcVolumeScale))}}
+	}
+	st, err := s.status(ctx, params)
+	if err != nil {
+		return 0, err
+	}
+	return st.Volume / vlcVolumeScale, nil
+}
+
+func (s *vlcSession) Position(ctx context.Context) (int64, error) {
+	st, err := s.status(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return st.Time * 1e6, nil
+}
+
+func (s *vlcSession) Duration(ctx context.Context) (int64, error) {
+	st, err := s.status(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return st.Length * 1e6, nil
+}
+
+func (s *vlcSession) ListAudio(ctx context.Context) ([]string, error) {
+	return s.tracks(ctx, "Audio")
+}
+
+func (s *vlcSession) ListSubtitles(ctx context.Context) ([]string, error) {
+	return s.tracks(ctx, "Subtitle")
+}
+
+// tracks lists the streams of type typ in omxplayer's
+// "index:language:name:codec:active" format. VLC does not report which one is
+// active.
+func (s *vlcSession) tracks(ctx context.Context, typ string) ([]string, error) {
+	st, err := s.status(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(st.Information.Category))
+	for name := range st.Information.Category {
+		if strings.HasPrefix(name, "Stream ") {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(names[i], "Stream "))
+		b, _ := strconv.Atoi(strings.TrimPrefix(names[j], "Stream "))
+		return a < b
+	})
+	list := make([]string, 0, len(names))
+	for _, name := range names {
+		info := st.Information.Category[name]
+		if fmt.Sprint(info["Type"]) != typ {
+			continue
+		}
+		field := func(k string) string {
+			if v, ok := info[k]; ok {
+				return fmt.Sprint(v)
+			}
+			return ""
+		}
+		list = append(list, fmt.Sprintf("%d:%s:%s:%s:", len(list), field("Language"), field("Description"), field("Codec")))
+	}
+	return list, nil
+}
@@ -0,0 +1,57 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sonnt85/goomx"
+)
+
+// TestVlcBackendStart starts a stand-in for cvlc that writes its command
+// line, config file and environment to files and exits.
+func TestVlcBackendStart(t *testing.T) {
+	dir := t.TempDir()
+	cvlc := filepath.Join(dir, "cvlc")
+	script := `#!/bin/sh
+echo "$@" > "$OUT/args"
+env > "$OUT/env"
+for a; do
+	case "$a" in
+	--config=*) cat "${a#--config=}" > "$OUT/vlcrc"; ls -l "${a#--config=}" > "$OUT/mode";;
+	esac
+done
+`
+	if err := os.WriteFile(cvlc, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+	b := &goomx.VlcBackend{Binary: cvlc}
+	if _, err := b.Start(ctx, clips(t, "a.mp4")[0], nil, []string{"OUT=" + dir, "DISPLAY=:7"}); err == nil {
+		t.Fatal("Start succeeded without an HTTP interface")
+	}
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	if args := read("args"); strings.Contains(args, "password") {
+		t.Errorf("password on the command line: %s", args)
+	}
+	if rc := read("vlcrc"); !strings.Contains(rc, "http-password=") {
+		t.Errorf("config file lacks the password:\n%s", rc)
+	}
+	if mode := read("mode"); !strings.HasPrefix(mode, "-rw-------") {
+		t.Errorf("config file readable by others: %s", mode)
+	}
+	if env := read("env"); !strings.Contains(env, "DISPLAY=:7\n") {
+		t.Errorf("cvlc environment\n%s\nlacks DISPLAY=:7", env)
+	}
+}
@@ -0,0 +1,58 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"time"
+
+	"github.com/sonnt85/gosutils/slogrus"
+)
+
+// stallChecks is how many times per StallTimeout the watchdog samples the
+// position.
+const stallChecks = 5
+
+// watchStall samples the position of session s, playing path, until ctx is
+// done. If the position stops advancing for StallTimeout while s is
+// "Playing", or s does not answer for as long, it emits an EventStalled and
+// returns the ErrStalled error the caller reports after killing s.
+func (p *Player) watchStall(ctx context.Context, s Session, path string) error {
+	timeout := p.config.StallTimeout
+	if timeout <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(timeout / stallChecks)
+	defer ticker.Stop()
+	last := int64(-1)
+	since := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		status, err := s.PlaybackStatus(ctx)
+		var pos int64
+		if err == nil {
+			pos, err = s.Position(ctx)
+		}
+		// a failed sample counts as no progress: a wedged player does not
+		// answer at all
+		if err == nil && (status != "Playing" || pos != last) {
+			last = pos
+			since = time.Now()
+			continue
+		}
+		if time.Since(since) >= timeout && ctx.Err() == nil {
+			if err != nil {
+				slogrus.Printf("Playback of %s does not answer: %s, killing player\n", path, err)
+			} else {
+				slogrus.Printf("Playback of %s stalled at %dus, killing player\n", path, pos)
+			}
+			err = &PlayerError{Op: "play", Path: path, Err: ErrStalled}
+			p.emitPlaying(EventStalled, err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,60 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+func TestStallRestart(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: time.Minute, StallAfter: 300 * time.Millisecond}, func(cfg *goomx.PlayerConfig) {
+		cfg.StallTimeout = 500 * time.Millisecond
+		cfg.CrashBackoff = 0
+	})
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4", "b.mp4"))
+	p.Play()
+
+	stalled := collect(t, events, goomx.EventStalled, 1)[0]
+	if filepath.Base(stalled.Path) != "a.mp4" || !errors.Is(stalled.Err, goomx.ErrStalled) {
+		t.Errorf("stalled event %+v, want ErrStalled for a.mp4", stalled)
+	}
+	finished := collect(t, events, goomx.EventFinished, 1)[0]
+	if kind := goomx.ClassifyCrash(finished.Err); kind != goomx.CrashStall || finished.Duration > 5*time.Second {
+		t.Errorf("a finished after %v with %v, a crash of kind %s, want a stall", finished.Duration, finished.Err, kind)
+	}
+	if next := collect(t, events, goomx.EventStarted, 1); !equal(paths(next), []string{"b.mp4"}) {
+		t.Errorf("started %v after the stall, want b.mp4", paths(next))
+	}
+}
+
+func TestStallNotWhilePaused(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: time.Minute}, func(cfg *goomx.PlayerConfig) {
+		cfg.StallTimeout = 300 * time.Millisecond
+	})
+	events := p.Events()
+	p.ConfigureNewPlaylist(clips(t, "a.mp4"))
+	p.Play()
+
+	collect(t, events, goomx.EventStarted, 1)
+	if err := p.CmdPause(); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == goomx.EventStalled || ev.Type == goomx.EventFinished {
+				t.Fatalf("%s while paused: %v", ev.Type, ev.Err)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
@@ -0,0 +1,330 @@
+//go:build linux
+
+package goomx
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sonnt85/gosutils/slogrus"
+)
+
+// defaultWatchDebounce is how long a watched folder must be quiet before it
+// is rescanned.
+const defaultWatchDebounce = time.Second
+
+// SortOrder is the order of the entries of a watched folder.
+type SortOrder int
+
+const (
+	SortByName    SortOrder = iota // by path, byte-wise
+	SortNatural                    // by path, digit runs compared by value: clip2 before clip10
+	SortByModTime                  // oldest first, then by path
+)
+
+// WatchFolderOptions configures WatchFolder.
+type WatchFolderOptions struct {
+	// Recursive includes the files of subdirectories.
+	Recursive bool
+	// Extensions are the file extensions to include, e.g. ".mp4", compared
+	// case-insensitively. If empty, every file is included.
+	Extensions []string
+	// Sort is the playlist order.
+	Sort SortOrder
+	// Debounce is how long the folder must be quiet after a change before it
+	// is rescanned, so files still being copied are not picked up half
+	// written. 1 second if zero.
+	Debounce time.Duration
+}
+
+// FolderWatch keeps a player's playlist in sync with a directory; see
+// WatchFolder.
+type FolderWatch struct {
+	p      *Player
+	dir    string
+	opts   WatchFolderOptions
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	listed  []string // entries found by the last scan
+	applied []string // playlist last set
+	pending string   // playing entry kept in the playlist after it was removed
+}
+
+// WatchFolder builds the playlist from the files in dir and keeps it in sync
+// as files are added, removed or renamed, until Stop is called or the player
+// is closed. Hidden files and directories are ignored. The playlist is only
+// replaced when the set of files changes, without interrupting the clip that
+// is playing; a playing file that is removed stays in the playlist until it
+// finishes. It fails with ErrClosed if the player is closed.
+func (p *Player) WatchFolder(dir string, opts WatchFolderOptions) (*FolderWatch, error) {
+	if p.ctx.Err() != nil {
+		return nil, &PlayerError{Op: "watch folder", Path: dir, Err: ErrClosed}
+	}
+	if opts.Debounce <= 0 {
+		opts.Debounce = defaultWatchDebounce
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, &PlayerError{Op: "watch folder", Path: dir, Err: err}
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, &PlayerError{Op: "watch folder", Path: dir, Err: err}
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	w := &FolderWatch{p: p, dir: dir, opts: opts, cancel: cancel, done: make(chan struct{})}
+	if err = w.addWatches(watcher, dir); err != nil {
+		cancel()
+		watcher.Close()
+		return nil, &PlayerError{Op: "watch folder", Path: dir, Err: err}
+	}
+	w.sync("")
+	events := p.Events()
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(w.done)
+		defer watcher.Close()
+		defer p.Unsubscribe(events)
+		w.run(ctx, watcher, events)
+	}()
+	return w, nil
+}
+
+// Stop stops watching. The playlist is left as it is.
+func (w *FolderWatch) Stop() {
+	w.cancel()
+	<-w.done
+}
+
+// run rescans the folder once it has been quiet for the debounce time after
+// a change, and once a removed entry that was kept while playing finishes.
+func (w *FolderWatch) run(ctx context.Context, watcher *fsnotify.Watcher, events <-chan PlayerEvent) {
+	debounce := time.NewTimer(w.opts.Debounce)
+	debounce.Stop()
+	defer debounce.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if ev.Has(fsnotify.Create) && w.opts.Recursive {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() && !hidden(fi.Name()) {
+					if err := w.addWatches(watcher, ev.Name); err != nil {
+						slogrus.Error("Can not watch ", ev.Name, ": ", err)
+					}
+				}
+			}
+			if ev.Op != fsnotify.Chmod {
+				debounce.Reset(w.opts.Debounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slogrus.Error("Watch folder ", w.dir, ": ", err)
+		case ev := <-events:
+			if w.pending != "" && ev.Path == w.pending && (ev.Type == EventFinished || ev.Type == EventFailed) {
+				w.sync(ev.Path)
+			}
+		case <-debounce.C:
+			w.sync("")
+		}
+	}
+}
+
+// addWatches watches dir and, if recursive, its subdirectories.
+func (w *FolderWatch) addWatches(watcher *fsnotify.Watcher, dir string) error {
+	if !w.opts.Recursive {
+		return watcher.Add(dir)
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && hidden(d.Name()) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+func hidden(name string) bool { return strings.HasPrefix(name, ".") }
+
+// scan returns the files of the folder in playlist order.
+func (w *FolderWatch) scan() ([]string, error) {
+	type file struct {
+		path    string
+		modTime time.Time
+	}
+	var files []file
+	err := filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == w.dir {
+				return err
+			}
+			return nil // vanished while walking
+		}
+		if path == w.dir {
+			return nil
+		}
+		if hidden(d.Name()) || (d.IsDir() && !w.opts.Recursive) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !w.included(path) {
+			return nil
+		}
+		fi, err := os.Stat(path) // follows symlinks
+		if err != nil || !fi.Mode().IsRegular() {
+			return nil
+		}
+		files = append(files, file{path: path, modTime: fi.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		switch w.opts.Sort {
+		case SortNatural:
+			return naturalLess(files[i].path, files[j].path)
+		case SortByModTime:
+			if !files[i].modTime.Equal(files[j].modTime) {
+				return files[i].modTime.Before(files[j].modTime)
+			}
+		}
+		return files[i].path < files[j].path
+	})
+	list := make([]string, len(files))
+	for i, f := range files {
+		list[i] = f.path
+	}
+	return list, nil
+}
+
+// included reports whether path has one of the wanted extensions.
+func (w *FolderWatch) included(path string) bool {
+	if len(w.opts.Extensions) == 0 {
+		return true
+	}
+	ext := filepath.Ext(path)
+	for _, e := range w.opts.Extensions {
+		if strings.EqualFold(ext, e) || strings.EqualFold(ext, "."+e) {
+			return true
+		}
+	}
+	return false
+}
+
+// sync rescans the folder and updates the playlist if the set of files
+// changed. finished is an entry that has just finished playing, so is no
+// longer kept even if still reported as playing.
+func (w *FolderWatch) sync(finished string) {
+	list, err := w.scan()
+	if err != nil {
+		slogrus.Error("Can not scan ", w.dir, ": ", err)
+		return
+	}
+	p := w.p
+	playing, _ := p.nowPlaying()
+	keep := ""
+	if path := playing.pathFile; path != "" && path != finished && !slices.Contains(list, path) &&
+		(slices.Contains(w.listed, path) || path == w.pending) {
+		keep = path
+	}
+	w.listed, w.pending = list, keep
+	if keep != "" {
+		at := slices.Index(p.GetPlaylist(), keep)
+		if at < 0 || at > len(list) {
+			at = len(list)
+		}
+		list = append(list[:at:at], append([]string{keep}, list[at:]...)...)
+	}
+	if reflect.DeepEqual(list, w.applied) {
+		return
+	}
+	w.applied = list
+	slogrus.Printf("Watch folder %s: %d entries\n", w.dir, len(list))
+	p.replacePlaylist(list)
+}
+
+// replacePlaylist replaces the playlist with list without interrupting
+// playback: the entry after the current one in the old playlist, if still
+// listed, is played next.
+func (p *Player) replacePlaylist(list []string) {
+	p.pruneItems(list)
+	p.configureNewPlaylist(list, false, func(old []string, current string) {
+		at := slices.Index(old, current)
+		if at < 0 || len(list) == 0 {
+			return // played from the start
+		}
+		// the queue moves on from the cursor, so put it on the current entry
+		// or, if that is gone, on the last remaining entry before it
+		p.queueHold = false
+		if i := slices.Index(list, current); i >= 0 {
+			p.seekEntry(i)
+			return
+		}
+		for i := at - 1; i >= 0; i-- {
+			if j := slices.Index(list, old[i]); j >= 0 {
+				p.seekEntry(j)
+				return
+			}
+		}
+		p.seekEntry(-1)
+	})
+}
+
+// naturalLess compares a and b with runs of digits compared by numeric
+// value.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			if len(da) != len(db) {
+				return len(da) < len(db)
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// digitPrefix returns the leading digits of s.
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
@@ -0,0 +1,106 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sonnt85/goomx"
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+// waitPlaylist waits until the base names of the playlist of p are want.
+func waitPlaylist(t *testing.T, p *goomx.Player, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(eventTimeout)
+	for !equal(p.GetPlaylistWithoutPath(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("playlist %v, want %v", p.GetPlaylistWithoutPath(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func touch(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWatchFolderDebounce(t *testing.T) {
+	const debounce = 500 * time.Millisecond
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	dir := t.TempDir()
+	touch(t, dir, "clip10.mp4", "clip2.mp4", ".hidden.mp4")
+	w, err := p.WatchFolder(dir, goomx.WatchFolderOptions{Extensions: []string{".mp4"}, Sort: goomx.SortNatural, Debounce: debounce})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	waitPlaylist(t, p, "clip2.mp4", "clip10.mp4")
+
+	// changes within the debounce time are applied together once it is quiet
+	touch(t, dir, "clip1.mp4")
+	time.Sleep(debounce / 5)
+	touch(t, dir, "notes.txt", "clip3.mp4")
+	changed := time.Now()
+	time.Sleep(debounce / 5)
+	if got := p.GetPlaylistWithoutPath(); !equal(got, []string{"clip2.mp4", "clip10.mp4"}) && time.Since(changed) < debounce {
+		t.Errorf("playlist %v before the folder was quiet", got)
+	}
+	waitPlaylist(t, p, "clip1.mp4", "clip2.mp4", "clip3.mp4", "clip10.mp4")
+
+	if err = os.Remove(filepath.Join(dir, "clip2.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	waitPlaylist(t, p, "clip1.mp4", "clip3.mp4", "clip10.mp4")
+}
+
+// TestWatchFolderKeepsPlaying removes the playing file, which stays in the
+// playlist until it has finished.
+func TestWatchFolderKeepsPlaying(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{Duration: time.Second}, nil)
+	dir := t.TempDir()
+	touch(t, dir, "a.mp4", "b.mp4")
+	w, err := p.WatchFolder(dir, goomx.WatchFolderOptions{Debounce: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	events := p.Events()
+	p.Play()
+
+	collect(t, events, goomx.EventStarted, 1)
+	if err = os.Remove(filepath.Join(dir, "a.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if got := p.GetPlaylistWithoutPath(); !equal(got, []string{"a.mp4", "b.mp4"}) {
+		t.Errorf("playlist %v while a.mp4 plays, want it kept", got)
+	}
+	next := collect(t, events, goomx.EventStarted, 1)
+	if got := paths(next); !equal(got, []string{"b.mp4"}) {
+		t.Errorf("started %v after a.mp4, want b.mp4", got)
+	}
+	waitPlaylist(t, p, "b.mp4")
+}
+
+func TestWatchFolderClosed(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.WatchFolder(t.TempDir(), goomx.WatchFolderOptions{}); !errors.Is(err, goomx.ErrClosed) {
+		t.Errorf("WatchFolder after Close: %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,141 @@
+//go:build linux
+
+package goomx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+// xspfNamespace is the XML namespace of XSPF version 1.
+const xspfNamespace = "http://xspf.org/ns/0/"
+
+// xspfApplication identifies the <extension> holding the goomx settings of a
+// track.
+const xspfApplication = "https://github.com/sonnt85/goomx"
+
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"http://xspf.org/ns/0/ playlist"`
+	Version string      `xml:"version,attr"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Locations  []string        `xml:"location"`
+	Title      string          `xml:"title,omitempty"`
+	Duration   int64           `xml:"duration,omitempty"` // milliseconds
+	Extensions []xspfExtension `xml:"extension"`
+}
+
+// xspfExtension is a track <extension>. Only the one of xspfApplication is
+// read; its fields hold the PlaylistItem settings XSPF has no element for.
+type xspfExtension struct {
+	Application string   `xml:"application,attr"`
+	Stream      bool     `xml:"stream,omitempty"`
+	Args        []string `xml:"arg"`
+	Start       int64    `xml:"start,omitempty"`   // milliseconds
+	MaxPlay     int64    `xml:"maxplay,omitempty"` // milliseconds
+	Volume      float64  `xml:"volume,omitempty"`
+	Loops       int      `xml:"loops,omitempty"`
+	Weight      float64  `xml:"weight,omitempty"`
+	Share       float64  `xml:"share,omitempty"` // percent
+	Tag         string   `xml:"tag,omitempty"`
+}
+
+// ParseXSPF parses an XSPF playlist. Each <track> becomes an item from its
+// first <location>, <title> and <duration>, plus the settings goomx stores
+// in its <extension>. Relative locations are resolved against dir.
+func ParseXSPF(r io.Reader, dir string) ([]PlaylistItem, error) {
+	var pl xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&pl); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPlaylistFormat, err)
+	}
+	if pl.XMLName.Space != xspfNamespace {
+		return nil, fmt.Errorf("%w: not an XSPF playlist", ErrPlaylistFormat)
+	}
+	items := make([]PlaylistItem, 0, len(pl.Tracks))
+	for _, t := range pl.Tracks {
+		if len(t.Locations) == 0 || t.Locations[0] == "" {
+			continue
+		}
+		loc := t.Locations[0]
+		if urlScheme(loc) == "" { // a relative URI
+			if unescaped, err := url.PathUnescape(loc); err == nil {
+				loc = unescaped
+			}
+		}
+		item := PlaylistItem{
+			Path:     resolveLocation(loc, dir),
+			Title:    t.Title,
+			Duration: time.Duration(t.Duration) * time.Millisecond,
+		}
+		for _, ext := range t.Extensions {
+			if ext.Application != xspfApplication {
+				continue
+			}
+			item.Stream = ext.Stream
+			item.Args = ext.Args
+			item.Start = time.Duration(ext.Start) * time.Millisecond
+			item.MaxPlay = time.Duration(ext.MaxPlay) * time.Millisecond
+			item.Volume = ext.Volume
+			item.Loops = ext.Loops
+			item.Weight = ext.Weight
+			item.Share = ext.Share
+			item.Tag = ext.Tag
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// WriteXSPF writes items as an XSPF playlist. Settings without an XSPF
+// element are kept in a goomx <extension>.
+func WriteXSPF(w io.Writer, items []PlaylistItem) error {
+	pl := xspfPlaylist{Version: "1", Tracks: make([]xspfTrack, len(items))}
+	for i, item := range items {
+		loc := item.Path
+		if urlScheme(loc) == "" {
+			abs, err := filepath.Abs(loc)
+			if err != nil {
+				return err
+			}
+			loc = (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+		}
+		t := xspfTrack{
+			Locations: []string{loc},
+			Title:     item.Title,
+			Duration:  item.Duration.Milliseconds(),
+		}
+		ext := xspfExtension{
+			Application: xspfApplication,
+			Stream:      item.Stream,
+			Args:        item.Args,
+			Start:       item.Start.Milliseconds(),
+			MaxPlay:     item.MaxPlay.Milliseconds(),
+			Volume:      item.Volume,
+			Loops:       item.Loops,
+			Weight:      item.Weight,
+			Share:       item.Share,
+			Tag:         item.Tag,
+		}
+		if ext.Stream || len(ext.Args) != 0 || ext.Start != 0 || ext.MaxPlay != 0 || ext.Volume != 0 || ext.Loops != 0 ||
+			ext.Weight != 0 || ext.Share != 0 || ext.Tag != "" {
+			t.Extensions = []xspfExtension{ext}
+		}
+		pl.Tracks[i] = t
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(pl); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
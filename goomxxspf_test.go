@@ -0,0 +1,45 @@
+//go:build linux
+
+package goomx_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sonnt85/goomx/goomxtest"
+)
+
+func TestPlaylistFileXSPFRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	items := playlistItems(dir)
+	if got := readBack(t, filepath.Join(dir, "list.xspf"), items); !reflect.DeepEqual(got, items) {
+		t.Errorf("read back\n%+v\nwant\n%+v", got, items)
+	}
+}
+
+func TestSaveLoadPlaylistFile(t *testing.T) {
+	p := newPlayer(t, goomxtest.Script{}, nil)
+	dir := t.TempDir()
+	items := playlistItems(dir)
+	if _, err := p.ConfigureNewPlaylistItems(items); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "saved.xspf")
+	if err := p.SavePlaylistFile(name); err != nil {
+		t.Fatal(err)
+	}
+
+	q := newPlayer(t, goomxtest.Script{}, nil)
+	if changed, err := q.LoadPlaylistFile(name); err != nil || !changed {
+		t.Fatalf("LoadPlaylistFile = %t, %v", changed, err)
+	}
+	if got := q.GetPlaylistItems(); !reflect.DeepEqual(got, items) {
+		t.Errorf("loaded\n%+v\nwant\n%+v", got, items)
+	}
+	if _, err := q.LoadPlaylistFile(filepath.Join(dir, "missing.m3u")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadPlaylistFile of a missing file: %v", err)
+	}
+}